@@ -0,0 +1,31 @@
+package slackbot
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	notifyAlert = "alert"
+	textAlert   = ":warning: bot の処理で問題が起きています: %s"
+)
+
+// alert tells the organizers in the alert channel about a problem of the bot, once a day for the same message
+func alert(ctx context.Context, w http.ResponseWriter, message string, now time.Time) {
+	sum := sha1.Sum([]byte(message))
+	key := now.Format("2006-01-02") + " " + hex.EncodeToString(sum[:])
+	if !loadSent(ctx, notifyAlert, key).LastSentAt.IsZero() {
+		return
+	}
+
+	channel := currentSettings(ctx).AlertChannel
+	if channel == "" {
+		channel = defaultSeriesConfig.ManageChannel
+	}
+	notify(ctx, w, notifyAlert, Event{}, channel, fmt.Sprintf(textAlert, message))
+	markSent(ctx, notifyAlert, key, now)
+}
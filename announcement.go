@@ -0,0 +1,138 @@
+package slackbot
+
+import (
+	"context"
+	"net/url"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const announcementKind = "Announcement"
+
+// rsvpReactions are the reactions counted as attendance in Slack
+var rsvpReactions = map[string]bool{
+	"raised_hand": true,
+	"hand":        true,
+}
+
+// Announcement is the main announcement message of an event, keyed by event ID
+type Announcement struct {
+	ChannelID string
+	TS        string
+	Pinned    bool
+}
+
+func loadAnnouncement(ctx context.Context, event Event) (Announcement, bool) {
+	key := eventKey(ctx, announcementKind, event.URL)
+
+	var announcement Announcement
+	if err := datastore.Get(ctx, key, &announcement); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "announcement get %s: %v", event.URL, err)
+		}
+		return Announcement{}, false
+	}
+
+	return announcement, true
+}
+
+func saveAnnouncement(ctx context.Context, event Event, announcement Announcement) {
+	key := eventKey(ctx, announcementKind, event.URL)
+
+	if _, err := datastore.Put(ctx, key, &announcement); err != nil {
+		log.Errorf(ctx, "announcement put %s: %v", event.URL, err)
+	}
+}
+
+// pinAnnouncement pins the posted announcement and remembers it for unpinning
+// ref: https://api.slack.com/methods/pins.add
+func pinAnnouncement(ctx context.Context, event Event, posted slackAPIResponse) {
+	if posted.TS == "" {
+		return
+	}
+
+	announcement := Announcement{ChannelID: posted.Channel, TS: posted.TS}
+	if _, err := callSlackAPI(ctx, "pins.add", map[string]interface{}{
+		"channel":   posted.Channel,
+		"timestamp": posted.TS,
+	}); err != nil {
+		log.Errorf(ctx, "pin %s: %v", event.URL, err)
+	} else {
+		announcement.Pinned = true
+	}
+
+	saveAnnouncement(ctx, event, announcement)
+}
+
+// unpinAnnouncement unpins the announcement of the event, if any
+// ref: https://api.slack.com/methods/pins.remove
+func unpinAnnouncement(ctx context.Context, event Event) {
+	announcement, ok := loadAnnouncement(ctx, event)
+	if !ok || !announcement.Pinned {
+		return
+	}
+
+	if _, err := callSlackAPI(ctx, "pins.remove", map[string]interface{}{
+		"channel":   announcement.ChannelID,
+		"timestamp": announcement.TS,
+	}); err != nil {
+		log.Errorf(ctx, "unpin %s: %v", event.URL, err)
+		return
+	}
+
+	announcement.Pinned = false
+	saveAnnouncement(ctx, event, announcement)
+}
+
+// countRSVP counts users who reacted with ✋ to the announcement, -1 if unknown
+func countRSVP(ctx context.Context, event Event) int {
+	users, ok := rsvpUsers(ctx, event)
+	if !ok {
+		return -1
+	}
+	return len(users)
+}
+
+// rsvpUsers returns the users who reacted with ✋ to the announcement
+// ref: https://api.slack.com/methods/reactions.get
+func rsvpUsers(ctx context.Context, event Event) ([]string, bool) {
+	announcement, ok := loadAnnouncement(ctx, event)
+	if !ok {
+		return nil, false
+	}
+
+	params := url.Values{}
+	params.Set("channel", announcement.ChannelID)
+	params.Set("timestamp", announcement.TS)
+	params.Set("full", "true")
+
+	var result struct {
+		Message struct {
+			Reactions []struct {
+				Name  string   `json:"name"`
+				Users []string `json:"users"`
+			} `json:"reactions"`
+		} `json:"message"`
+	}
+	if err := callSlackAPIGet(ctx, "reactions.get", params, &result); err != nil {
+		log.Errorf(ctx, "reactions %s: %v", event.URL, err)
+		return nil, false
+	}
+
+	seen := map[string]bool{}
+	var users []string
+	for _, reaction := range result.Message.Reactions {
+		if !rsvpReactions[reaction.Name] {
+			continue
+		}
+		for _, user := range reaction.Users {
+			if !seen[user] {
+				seen[user] = true
+				users = append(users, user)
+			}
+		}
+	}
+
+	return users, true
+}
@@ -0,0 +1,257 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	approvalKind          = "PendingApproval"
+	approveAction         = "approve_announcement"
+	rejectAction          = "reject_announcement"
+	textApprovalRequest   = "%s に次のお知らせを投稿します。確認して承認してください (%s までに反応が無ければ自動で投稿します)\n>>>%s"
+	textApproved          = "<@%s> が承認しました。次の定期実行で %s に投稿します。"
+	textRejected          = "<@%s> が却下しました。%s には投稿しません。"
+	textApprovalTimedOut  = "承認期限を過ぎたため %s に投稿しました。"
+	textApprovalNoRole    = "承認・却下する権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+	defaultApprovalPeriod = 2 * time.Hour
+)
+
+// approval states
+const (
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalRejected = "rejected"
+	approvalSent     = "sent"
+)
+
+// broadcastMentionRe matches mentions notifying the whole channel
+var broadcastMentionRe = regexp.MustCompile(`<!(channel|here|everyone)(\|[^>]*)?>`)
+
+// PendingApproval is a high-impact notification waiting for an organizer in #manage
+type PendingApproval struct {
+	Type        string
+	EventURL    string
+	Channel     string
+	Text        string `datastore:",noindex"`
+	BlocksJSON  string `datastore:",noindex"`
+	EventJSON   string `datastore:",noindex"`
+	Status      string
+	ChannelID   string
+	TS          string
+	UserID      string
+	RequestedAt time.Time
+}
+
+// needsApproval reports whether the notification mentions the whole channel or goes to one of approval_channels
+func needsApproval(ctx context.Context, channel, text string) bool {
+	s := currentSettings(ctx)
+	if !s.Approval || botToken(ctx) == "" {
+		return false
+	}
+	if broadcastMentionRe.MatchString(text) {
+		return true
+	}
+	for _, c := range s.ApprovalChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// requestApproval holds the notification and asks #manage to approve it
+// ref: https://api.slack.com/reference/block-kit/block-elements#button
+func requestApproval(ctx context.Context, w http.ResponseWriter, kind string, event Event, channel, text string, blocks []interface{}) {
+	eventJSON, _ := json.Marshal(event)
+	approval := PendingApproval{
+		Type:        kind,
+		EventURL:    event.URL,
+		Channel:     channel,
+		Text:        text,
+		EventJSON:   string(eventJSON),
+		Status:      approvalPending,
+		RequestedAt: time.Now(),
+	}
+	if len(blocks) > 0 {
+		blocksJSON, _ := json.Marshal(blocks)
+		approval.BlocksJSON = string(blocksJSON)
+	}
+
+	key, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, approvalKind, nil), &approval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deadline := approval.RequestedAt.Add(currentSettings(ctx).approvalTimeout).Format("1/2 15:04")
+	request := fmt.Sprintf(textApprovalRequest, channel, deadline, text)
+	id := strconv.FormatInt(key.IntID(), 10)
+	posted, err := callSlackAPI(ctx, "chat.postMessage", map[string]interface{}{
+		"channel": seriesConfigFor(ctx, event).ManageChannel,
+		"text":    request,
+		"blocks": []interface{}{
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]interface{}{"type": "mrkdwn", "text": request},
+			},
+			map[string]interface{}{
+				"type": "actions",
+				"elements": []interface{}{
+					map[string]interface{}{
+						"type":      "button",
+						"text":      map[string]interface{}{"type": "plain_text", "text": "承認"},
+						"style":     "primary",
+						"action_id": approveAction,
+						"value":     id,
+					},
+					map[string]interface{}{
+						"type":      "button",
+						"text":      map[string]interface{}{"type": "plain_text", "text": "却下"},
+						"style":     "danger",
+						"action_id": rejectAction,
+						"value":     id,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		// nobody can approve it, so the timeout will send it
+		log.Errorf(ctx, "approval request %s: %v", kind, err)
+		return
+	}
+
+	approval.ChannelID, approval.TS = posted.Channel, posted.TS
+	if _, err := datastore.Put(ctx, key, &approval); err != nil {
+		log.Errorf(ctx, "approval put %s: %v", kind, err)
+	}
+}
+
+// decideApproval records the decision of an organizer and updates the request in #manage
+func decideApproval(ctx context.Context, userID, channelID, value string, approved bool) error {
+	if userRole(userID) < roleOrganizer {
+		_, err := callSlackAPI(ctx, "chat.postEphemeral", map[string]interface{}{
+			"channel": channelID,
+			"user":    userID,
+			"text":    textApprovalNoRole,
+		})
+		return err
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	key := datastore.NewKey(ctx, approvalKind, "", id, nil)
+
+	var approval PendingApproval
+	err = datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		if err := datastore.Get(tc, key, &approval); err != nil {
+			return err
+		}
+		// the first decision wins
+		if approval.Status != approvalPending {
+			return nil
+		}
+
+		approval.Status = approvalRejected
+		if approved {
+			approval.Status = approvalApproved
+		}
+		approval.UserID = userID
+		_, err := datastore.Put(tc, key, &approval)
+		return err
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf(textRejected, approval.UserID, approval.Channel)
+	if approval.Status != approvalRejected {
+		text = fmt.Sprintf(textApproved, approval.UserID, approval.Channel)
+	}
+	text += "\n>>>" + approval.Text
+	return updateMessage(ctx, approval.ChannelID, approval.TS, text, announcementBlocks(text, "", "")...)
+}
+
+// claimApproval marks the approval as sent when it is still in status, so that overlapping cron runs post it once
+func claimApproval(ctx context.Context, key *datastore.Key, status string) bool {
+	claimed := false
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var approval PendingApproval
+		if err := datastore.Get(tc, key, &approval); err != nil {
+			return err
+		}
+		if approval.Status != status {
+			return nil
+		}
+
+		approval.Status = approvalSent
+		if _, err := datastore.Put(tc, key, &approval); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "approval claim: %v", err)
+		return false
+	}
+	return claimed
+}
+
+// releaseApprovals sends the approved notifications and those left undecided beyond approval_timeout
+func releaseApprovals(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	var approvals []PendingApproval
+	var keys []*datastore.Key
+	for _, status := range []string{approvalPending, approvalApproved} {
+		var found []PendingApproval
+		foundKeys, err := datastore.NewQuery(approvalKind).Filter("Status =", status).GetAll(ctx, &found)
+		if err != nil {
+			log.Errorf(ctx, "approval query: %v", err)
+			return
+		}
+		approvals, keys = append(approvals, found...), append(keys, foundKeys...)
+	}
+
+	for i, approval := range approvals {
+		timedOut := approval.Status == approvalPending && now.Sub(approval.RequestedAt) >= currentSettings(ctx).approvalTimeout
+		if approval.Status != approvalApproved && !timedOut {
+			continue
+		}
+
+		var event Event
+		json.Unmarshal([]byte(approval.EventJSON), &event)
+		var blocks []interface{}
+		if approval.BlocksJSON != "" {
+			json.Unmarshal([]byte(approval.BlocksJSON), &blocks)
+		}
+
+		if !claimApproval(ctx, keys[i], approval.Status) {
+			continue
+		}
+
+		notify(ctx, w, approval.Type, event, approval.Channel, approval.Text, blocks...)
+		if rule, ok := rules.Find(approval.Type); ok && rule.Announcement {
+			crossPost(ctx, w, event, approval.Text, blocks...)
+			fanOutChannels(ctx, w, approval.Type, event, approval.Text, blocks...)
+		}
+
+		if timedOut && approval.TS != "" {
+			text := fmt.Sprintf(textApprovalTimedOut, approval.Channel) + "\n>>>" + approval.Text
+			if err := updateMessage(ctx, approval.ChannelID, approval.TS, text, announcementBlocks(text, "", "")...); err != nil {
+				log.Errorf(ctx, "approval update: %v", err)
+			}
+		}
+	}
+}
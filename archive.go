@@ -0,0 +1,88 @@
+package slackbot
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const archiveKind = "ArchivedEvent"
+
+// ArchivedEvent is the latest known data of an event, kept after the event ends
+type ArchivedEvent struct {
+	Title     string
+	URL       string
+	SeriesID  int
+	StartedAt time.Time
+	EndedAt   time.Time
+	Place     string
+	Limit     int
+	Accepted  int
+}
+
+// fillRate returns accepted/limit, or 0 when the limit is unknown
+func (a ArchivedEvent) fillRate() float64 {
+	if a.Limit <= 0 {
+		return 0
+	}
+	return float64(a.Accepted) / float64(a.Limit)
+}
+
+func archiveEvent(ctx context.Context, event Event) {
+	key := eventKey(ctx, archiveKind, event.URL)
+
+	archived := ArchivedEvent{
+		Title:     event.Title,
+		URL:       event.URL,
+		SeriesID:  event.SeriesID,
+		StartedAt: event.StartedAt,
+		EndedAt:   event.EndedAt,
+		Place:     event.Place,
+		Limit:     event.Limit,
+		Accepted:  event.Accepted,
+	}
+	if _, err := datastore.Put(ctx, key, &archived); err != nil {
+		log.Errorf(ctx, "archive put %s: %v", event.URL, err)
+		return
+	}
+
+	// events archived before they were keyed by their ID would be counted twice
+	for _, legacyURL := range []string{event.URL, event.RawURL} {
+		legacy := datastore.NewKey(ctx, archiveKind, legacyURL, 0, nil)
+		if legacyURL == "" || legacy.Equal(key) {
+			continue
+		}
+		if err := datastore.Delete(ctx, legacy); err != nil && err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "archive delete %s: %v", legacyURL, err)
+		}
+	}
+}
+
+// archivedEvents returns archived events started in [from, to), oldest first
+func archivedEvents(ctx context.Context, from, to time.Time) []ArchivedEvent {
+	var events []ArchivedEvent
+	q := datastore.NewQuery(archiveKind).
+		Filter("StartedAt >=", from).
+		Filter("StartedAt <", to).
+		Order("StartedAt")
+	if _, err := q.GetAll(ctx, &events); err != nil {
+		log.Errorf(ctx, "archive query: %v", err)
+	}
+
+	return events
+}
+
+// previousEvent returns the most recent ended event of the same series
+func previousEvent(ctx context.Context, event Event) (ArchivedEvent, bool) {
+	var previous ArchivedEvent
+	found := false
+	for _, archived := range archivedEvents(ctx, time.Time{}, event.StartedAt) {
+		if archived.SeriesID == event.SeriesID && archived.URL != event.URL && isEnded(archived.EndedAt) {
+			previous, found = archived, true
+		}
+	}
+
+	return previous, found
+}
@@ -0,0 +1,52 @@
+package slackbot
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const auditKind = "AuditEntry"
+
+// auditURLRe finds the URLs that are redacted in the audit entries of the streaming URL
+var auditURLRe = regexp.MustCompile(`https?://[^\s>|]+`)
+
+// audit actions
+const (
+	auditSent         = "sent"
+	auditDeferred     = "deferred"
+	auditFailed       = "failed"
+	auditScheduled    = "scheduled"
+	auditRetracted    = "retracted"
+	auditTransitioned = "transitioned"
+	auditSuppressed   = "suppressed"
+)
+
+// AuditEntry is a history record of what the bot did
+type AuditEntry struct {
+	Action    string
+	Type      string
+	Channel   string
+	ChannelID string
+	TS        string
+	EventURL  string
+	Text      string `datastore:",noindex"`
+	Detail    string `datastore:",noindex"`
+	CreatedAt time.Time
+}
+
+func recordAudit(ctx context.Context, entry AuditEntry) {
+	entry.CreatedAt = time.Now()
+	// the streaming URL is for the participants only, and the audit log is shown on the dashboard and in state exports
+	if entry.Type == notifyStream {
+		entry.Text = auditURLRe.ReplaceAllString(entry.Text, "[redacted]")
+	}
+
+	key := datastore.NewIncompleteKey(ctx, auditKind, nil)
+	if _, err := datastore.Put(ctx, key, &entry); err != nil {
+		log.Errorf(ctx, "audit put: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/appengine"
+)
+
+const (
+	backfillPageSize = 100
+	backfillMaxPages = 20
+	// connpass asks clients to space out their requests
+	backfillInterval = time.Second
+)
+
+// fetchEventPage fetches a page of the events of the series, newest first,
+// and returns them with the number of events available
+// ref: https://connpass.com/about/api/
+func fetchEventPage(ctx context.Context, seriesIDs string, start int) ([]Event, int, error) {
+	query := url.Values{}
+	query.Set("series_id", seriesIDs)
+	query.Set("order", "2")
+	query.Set("start", strconv.Itoa(start))
+	query.Set("count", strconv.Itoa(backfillPageSize))
+
+	req, err := http.NewRequest(http.MethodGet, connpassURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client, cancel := outboundClient(ctx, connpassTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	var page struct {
+		ResultsAvailable int `json:"results_available"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, 0, err
+	}
+	events, err := parseEvents(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, page.ResultsAvailable, nil
+}
+
+// handleBackfill archives the past events of the series with POST /admin/backfill?community={name},
+// paging back through connpass so that reports cover the history before the deployment
+func handleBackfill(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, err := withCommunity(appengine.NewContext(r), r.FormValue("community"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	archived := 0
+	for page, start := 0, 1; page < backfillMaxPages; page, start = page+1, start+backfillPageSize {
+		if page > 0 {
+			time.Sleep(backfillInterval)
+		}
+
+		events, available, err := fetchEventPage(ctx, currentSettings(ctx).seriesIDs(), start)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("archived %d events before: %v", archived, err), http.StatusBadGateway)
+			return
+		}
+		for _, event := range events {
+			if isEnded(event.EndedAt) {
+				archiveEvent(ctx, event)
+				archived++
+			}
+		}
+
+		if len(events) == 0 || start+backfillPageSize > available {
+			break
+		}
+	}
+
+	fmt.Fprintf(w, "archived %d events\n", archived)
+}
@@ -0,0 +1,121 @@
+package slackbot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// nextPostAt is the earliest time of the next post per channel
+	channelMu  sync.Mutex
+	nextPostAt = map[string]time.Time{}
+)
+
+// errSendBudget is returned when a post would have to wait beyond the send budget of the cron run
+var errSendBudget = errors.New("send budget exceeded")
+
+type sendBudgetKey struct{}
+
+// withSendBudget makes posts in ctx give up pacing that would end after deadline
+func withSendBudget(ctx context.Context, deadline time.Time) context.Context {
+	return context.WithValue(ctx, sendBudgetKey{}, deadline)
+}
+
+// paceChannel keeps send_interval, or channel_interval when longer, between posts to the same channel,
+// so that fired rules don't land as a burst and stay within what Slack allows (about one per second).
+// Each caller reserves the next slot, so parallel sends to a channel line up while other channels go on.
+func paceChannel(ctx context.Context, channel string) error {
+	interval := currentSettings(ctx).channelInterval
+	if s := currentSettings(ctx).sendInterval; s > interval {
+		interval = s
+	}
+	if interval <= 0 {
+		return nil
+	}
+
+	channelMu.Lock()
+	now := time.Now()
+	at := nextPostAt[channel]
+	if at.Before(now) {
+		at = now
+	}
+	if deadline, ok := ctx.Value(sendBudgetKey{}).(time.Time); ok && at.After(deadline) {
+		channelMu.Unlock()
+		return errSendBudget
+	}
+	nextPostAt[channel] = at.Add(interval)
+	channelMu.Unlock()
+
+	if !at.After(now) {
+		return nil
+	}
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pendingNotification is a notification held until the end of the cron run
+type pendingNotification struct {
+	Kind    string
+	Event   Event
+	Channel string
+	Text    string
+	Blocks  []interface{}
+}
+
+func (r *runReport) queue(n pendingNotification) {
+	r.pending = append(r.pending, n)
+}
+
+// flush posts the queued notifications as one multi-section message per channel
+func (r *runReport) flush(ctx context.Context, w http.ResponseWriter) {
+	var channels []string
+	byChannel := map[string][]pendingNotification{}
+	for _, n := range r.pending {
+		if _, ok := byChannel[n.Channel]; !ok {
+			channels = append(channels, n.Channel)
+		}
+		byChannel[n.Channel] = append(byChannel[n.Channel], n)
+	}
+	r.pending = nil
+
+	for _, channel := range channels {
+		batch := byChannel[channel]
+
+		var texts []string
+		var blocks []interface{}
+		for i, n := range batch {
+			texts = append(texts, n.Text)
+			if i > 0 {
+				blocks = append(blocks, map[string]interface{}{"type": "divider"})
+			}
+			if len(n.Blocks) > 0 {
+				blocks = append(blocks, n.Blocks...)
+			} else {
+				blocks = append(blocks, announcementBlocks(n.Text, "", n.Event.Title)...)
+			}
+		}
+
+		// the identity of the first rule is used for the combined message
+		posted, err := sendSlack(ctx, batch[0].Kind, channel, strings.Join(texts, "\n"), blocks...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			for _, n := range batch {
+				deadLetter(ctx, n.Kind, n.Event, channel, n.Text, n.Blocks, err)
+			}
+			continue
+		}
+		for _, n := range batch {
+			delivered(ctx, n.Kind, n.Event, channel, n.Text, posted)
+		}
+	}
+}
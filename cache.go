@@ -0,0 +1,64 @@
+package slackbot
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	cacheKind = "ConnpassCache"
+	cacheName = "events"
+)
+
+// ConnpassCache is the last connpass response with its validators
+type ConnpassCache struct {
+	ETag         string
+	LastModified string
+	Body         []byte `datastore:",noindex"`
+	FetchedAt    time.Time
+	// MaintenanceSince is when connpass went into maintenance, zero when it is up
+	MaintenanceSince time.Time
+}
+
+func loadConnpassCache(ctx context.Context) ConnpassCache {
+	key := datastore.NewKey(ctx, cacheKind, cacheName, 0, nil)
+
+	var cache ConnpassCache
+	if err := datastore.Get(ctx, key, &cache); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "cache get: %v", err)
+	}
+
+	return cache
+}
+
+func saveConnpassCache(ctx context.Context, cache ConnpassCache) {
+	key := datastore.NewKey(ctx, cacheKind, cacheName, 0, nil)
+
+	if _, err := datastore.Put(ctx, key, &cache); err != nil {
+		log.Errorf(ctx, "cache put: %v", err)
+	}
+}
+
+// nextEvent returns the nearest upcoming event from the last fetched events
+func nextEvent(ctx context.Context) (Event, bool) {
+	events, err := parseEvents(loadConnpassCache(ctx).Body)
+	if err != nil {
+		return Event{}, false
+	}
+
+	var next Event
+	found := false
+	for _, event := range events {
+		if isEnded(event.EndedAt) {
+			continue
+		}
+		if !found || event.StartedAt.Before(next.StartedAt) {
+			next, found = event, true
+		}
+	}
+
+	return next, found
+}
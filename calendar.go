@@ -0,0 +1,119 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+)
+
+const calendarTimeFormat = "20060102T150405Z"
+
+// handleEventFile serves the files of an event under /events/{id}/
+func handleEventFile(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/qr.png"):
+		handleEventQRCode(w, r)
+	case strings.HasSuffix(r.URL.Path, "/event.ics"):
+		handleEventICS(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func calendarLocation(event Event) string {
+	return strings.TrimSpace(event.Place + " " + event.Address)
+}
+
+// googleCalendarURL returns the "Add to Google Calendar" link of the event
+// ref: https://github.com/InteractionDesignFoundation/add-event-to-calendar-docs/blob/main/services/google.md
+func googleCalendarURL(event Event) string {
+	query := url.Values{
+		"action":   {"TEMPLATE"},
+		"text":     {event.Title},
+		"dates":    {event.StartedAt.UTC().Format(calendarTimeFormat) + "/" + event.EndedAt.UTC().Format(calendarTimeFormat)},
+		"details":  {event.URL},
+		"location": {calendarLocation(event)},
+	}
+	return "https://calendar.google.com/calendar/render?" + query.Encode()
+}
+
+func icsURL(ctx context.Context, event Event) string {
+	return appBaseURL(ctx) + "/events/" + strconv.Itoa(event.ID) + "/event.ics"
+}
+
+// icsEscape escapes a text value of iCalendar
+// ref: https://datatracker.ietf.org/doc/html/rfc5545#section-3.3.11
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// eventICS renders the event as an iCalendar file
+// ref: https://datatracker.ietf.org/doc/html/rfc5545
+func eventICS(event Event, now time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//nfug-eventbot//JA",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%d@connpass.com", event.ID),
+		"DTSTAMP:" + now.UTC().Format(calendarTimeFormat),
+		"DTSTART:" + event.StartedAt.UTC().Format(calendarTimeFormat),
+		"DTEND:" + event.EndedAt.UTC().Format(calendarTimeFormat),
+		"SUMMARY:" + icsEscape(event.Title),
+		"LOCATION:" + icsEscape(calendarLocation(event)),
+		"URL:" + event.URL,
+		"DESCRIPTION:" + icsEscape(event.URL),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// handleEventICS serves /events/{id}/event.ics
+func handleEventICS(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	path := strings.TrimPrefix(r.URL.Path, "/events/")
+
+	id, err := strconv.Atoi(strings.TrimSuffix(path, "/event.ics"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	event, ok := findEvent(ctx, id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%d.ics"`, event.ID))
+	fmt.Fprint(w, eventICS(event, time.Now()))
+}
+
+// calendarBlock is the buttons to save the date of the event
+// ref: https://api.slack.com/reference/block-kit/block-elements#button
+func calendarBlock(ctx context.Context, event Event) map[string]interface{} {
+	button := func(actionID, text, url string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":      "button",
+			"action_id": actionID,
+			"text":      map[string]interface{}{"type": "plain_text", "text": text},
+			"url":       url,
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "actions",
+		"elements": []interface{}{
+			button("calendar_google", "Google カレンダーに追加", googleCalendarURL(event)),
+			button("calendar_ics", "カレンダーファイル (.ics)", icsURL(ctx, event)),
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+)
+
+const (
+	channelSubKind          = "ChannelSubscription"
+	textChannelSubscribed   = "このチャンネルでもイベントの告知を受け取るようにしました。/nfug unsubscribe で止められます。"
+	textChannelUnsubscribed = "このチャンネルへのイベントの告知を止めました。"
+	textChannelDefault      = "このチャンネルには設定で告知が届いています。"
+	textChannelNoBot        = "チャンネルへの告知には SLACK_BOT_TOKEN が必要です。"
+	textChannelNoRole       = "チャンネルの告知を変える権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+)
+
+// ChannelSubscription is a channel opted into the announcements by /nfug subscribe, keyed by channel ID
+type ChannelSubscription struct {
+	ChannelID string
+	UserID    string
+	CreatedAt time.Time
+}
+
+// commandSubscribe handles "/nfug subscribe" and "/nfug unsubscribe" in the channel to opt in or out
+func commandSubscribe(ctx context.Context, userID, channelID, channelName string, subscribe bool) string {
+	if userRole(userID) < roleOrganizer {
+		return textChannelNoRole
+	}
+	if botToken(ctx) == "" {
+		return textChannelNoBot
+	}
+	key := datastore.NewKey(ctx, channelSubKind, channelID, 0, nil)
+
+	if !subscribe {
+		if err := datastore.Delete(ctx, key); err != nil && err != datastore.ErrNoSuchEntity {
+			return err.Error()
+		}
+		return textChannelUnsubscribed
+	}
+
+	for _, series := range currentSettings(ctx).Series {
+		if series.GeneralChannel == "#"+channelName {
+			return textChannelDefault
+		}
+	}
+	if defaultSeriesConfig.GeneralChannel == "#"+channelName {
+		return textChannelDefault
+	}
+
+	subscription := ChannelSubscription{ChannelID: channelID, UserID: userID, CreatedAt: time.Now()}
+	if _, err := datastore.Put(ctx, key, &subscription); err != nil {
+		return err.Error()
+	}
+	return textChannelSubscribed
+}
+
+// fanOutChannels sends the announcement to the channels subscribed by /nfug subscribe, besides the configured ones
+func fanOutChannels(ctx context.Context, w http.ResponseWriter, kind string, event Event, text string, blocks ...interface{}) {
+	if botToken(ctx) == "" {
+		return
+	}
+
+	var subscriptions []ChannelSubscription
+	if _, err := datastore.NewQuery(channelSubKind).GetAll(ctx, &subscriptions); err != nil {
+		http.Error(w, fmt.Sprintf("channel subscriptions: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, subscription := range subscriptions {
+		notify(ctx, w, kind, event, subscription.ChannelID, text, blocks...)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !appengine
+// +build !appengine
+
+// Command nfug-eventbot serves the bot as a process, for App Engine runtimes that start a main package
+// instead of the go1 runtime deploying app.yaml.
+package main
+
+import slackbot "github.com/girigiribauer/nfug-eventbot"
+
+func main() {
+	slackbot.Serve()
+}
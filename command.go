@@ -0,0 +1,74 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/appengine"
+)
+
+// commandResponse is the reply to a slash command
+// ref: https://api.slack.com/interactivity/slash-commands#responding_to_commands
+type commandResponse struct {
+	ResponseType string        `json:"response_type"`
+	Text         string        `json:"text"`
+	Blocks       []interface{} `json:"blocks,omitempty"`
+}
+
+// handleCommand dispatches "/nfug <subcommand> args..."
+func handleCommand(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	body, err := verifySlackRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx = forTeam(ctx, form.Get("team_id"))
+	args := strings.Fields(form.Get("text"))
+	text := textCommandUsage
+	var blocks []interface{}
+	if len(args) > 0 {
+		switch args[0] {
+		case "remindme":
+			text = commandRemindMe(ctx, form.Get("user_id"), args[1:])
+		case "poll":
+			text = commandPoll(ctx, form.Get("user_id"), args[1:])
+		case "talk":
+			text = commandTalk(ctx, form.Get("user_id"), args[1:])
+		case "talks":
+			text = commandTalks(ctx)
+		case "stream":
+			text = commandStream(ctx, form.Get("user_id"), args[1:])
+		case "link":
+			text = commandLink(ctx, form.Get("user_id"), args[1:])
+		case "remind":
+			text = commandRemind(ctx, form.Get("user_id"), args[1:])
+		case "hiatus":
+			text = commandHiatus(ctx, form.Get("user_id"), args[1:])
+		case "token":
+			text = commandToken(form.Get("user_id"))
+		case "subscribe", "unsubscribe":
+			text = commandSubscribe(ctx, form.Get("user_id"), form.Get("channel_id"), form.Get("channel_name"), args[0] == "subscribe")
+		case "debug":
+			text = commandDebug(ctx, form.Get("user_id"))
+		case "stats":
+			text, blocks = commandStats(ctx)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commandResponse{
+		ResponseType: "ephemeral",
+		Text:         text,
+		Blocks:       blocks,
+	})
+}
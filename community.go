@@ -0,0 +1,70 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"google.golang.org/appengine"
+)
+
+// namespaceRe is what Datastore accepts as a namespace
+// ref: https://cloud.google.com/appengine/docs/standard/go/multitenancy/multitenancy
+var namespaceRe = regexp.MustCompile(`^[0-9A-Za-z._-]{1,100}$`)
+
+// communityNames returns the communities processed by a cron run, "" being the top level of settings.yaml
+func communityNames() []string {
+	names := []string{""}
+	for name := range globalSettings().communities {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// withCommunity scopes ctx to the community: its settings, its Slack team and its own Datastore namespace
+// so that snapshots, dedupe records and caches don't mix between communities
+func withCommunity(ctx context.Context, name string) (context.Context, error) {
+	s := globalSettings()
+	if name != "" {
+		community, ok := s.communities[name]
+		if !ok {
+			return ctx, fmt.Errorf("unknown community %q", name)
+		}
+		s = community
+	}
+
+	ctx, err := appengine.Namespace(ctx, name)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, settingsKey{}, s)
+
+	return withTeam(ctx, s.Team), nil
+}
+
+// forTeam scopes ctx to the community of the Slack team a request came from
+func forTeam(ctx context.Context, teamID string) context.Context {
+	name := ""
+	for n, community := range globalSettings().communities {
+		if teamID != "" && community.Team == teamID {
+			name = n
+		}
+	}
+
+	scoped, err := withCommunity(ctx, name)
+	if err != nil {
+		return withTeam(ctx, teamID)
+	}
+	return withTeam(scoped, teamID)
+}
+
+// rootContext is ctx in the default namespace, for data shared by all communities
+func rootContext(ctx context.Context) context.Context {
+	root, err := appengine.Namespace(ctx, "")
+	if err != nil {
+		return ctx
+	}
+	return root
+}
@@ -0,0 +1,89 @@
+package slackbot
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// configErrors holds problems found by loadConfig, reported by /healthz
+var configErrors []string
+
+func isHTTPSURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+// requireAllOrNone checks that related settings are either all set or all empty
+func requireAllOrNone(names ...string) error {
+	var set, unset []string
+	for _, name := range names {
+		if os.Getenv(name) == "" {
+			unset = append(unset, name)
+		} else {
+			set = append(set, name)
+		}
+	}
+	if len(set) > 0 && len(unset) > 0 {
+		return fmt.Errorf("%s must be set together with %s", strings.Join(unset, ", "), strings.Join(set, ", "))
+	}
+	return nil
+}
+
+// loadConfig validates all settings and returns the problems found
+func loadConfig() []string {
+	var errs []string
+
+	// the incoming webhook is only needed when neither the bot token nor installations via /slack/install post
+	if slackbotURL == "" {
+		if slackBotToken == "" && slackClientID == "" {
+			errs = append(errs, "SLACKBOT_URL is not set, and neither SLACK_BOT_TOKEN nor SLACK_CLIENT_ID is")
+		}
+	} else if !isHTTPSURL(slackbotURL) {
+		errs = append(errs, "SLACKBOT_URL is not a valid https URL")
+	}
+
+	for _, u := range outgoingWebhookURLs {
+		if !isHTTPSURL(u) {
+			errs = append(errs, fmt.Sprintf("OUTGOING_WEBHOOK_URLS: %q is not a valid https URL", u))
+		}
+	}
+
+	if err := loadSettingsFile(); err != nil {
+		errs = append(errs, fmt.Sprintf("%s: %v", settingsFile, err))
+	}
+
+	for _, group := range [][]string{
+		{"SLACK_BOT_TOKEN", "SLACK_SIGNING_SECRET"},
+		{"NOTION_TOKEN", "NOTION_DATABASE_ID"},
+		{"TRELLO_KEY", "TRELLO_TOKEN", "TRELLO_LIST_ID"},
+		{"SLACK_CLIENT_ID", "SLACK_CLIENT_SECRET"},
+	} {
+		if err := requireAllOrNone(group...); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	errs = append(errs, loadTimeoutConfig()...)
+
+	for _, e := range errs {
+		log.Printf("config: %s", e)
+	}
+
+	return errs
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if len(configErrors) > 0 {
+		http.Error(w, "misconfigured\n"+strings.Join(configErrors, "\n"), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+	fmt.Fprintf(w, "slack_throttled_total %d\n", atomic.LoadInt64(&slackThrottled))
+	fmt.Fprintf(w, "connpass_failures_total %d\n", atomic.LoadInt64(&connpassFailures))
+}
@@ -0,0 +1,222 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	// the rules are planned this far ahead, in steps that hit every regular hour window
+	dashboardHorizon = 15 * 24 * time.Hour
+	dashboardStep    = 30 * time.Minute
+	dashboardErrors  = 10
+	dashboardTrend   = 6
+)
+
+// dashboard is what /dashboard shows about the community
+type dashboard struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	// LastSync is when connpass last answered with events
+	LastSync         time.Time             `json:"last_sync"`
+	MaintenanceSince time.Time             `json:"maintenance_since,omitempty"`
+	ConnpassFailures int                   `json:"connpass_failures"`
+	Upcoming         []dashboardEvent      `json:"upcoming"`
+	Deferred         int                   `json:"deferred"`
+	DeadLetters      int                   `json:"dead_letters"`
+	PendingApprovals int                   `json:"pending_approvals"`
+	Errors           []AuditEntry          `json:"errors"`
+	Trend            []dashboardAttendance `json:"trend"`
+}
+
+// dashboardEvent is an upcoming event with the rules planned for it
+type dashboardEvent struct {
+	Title     string         `json:"title"`
+	URL       string         `json:"url"`
+	StartedAt time.Time      `json:"started_at"`
+	Accepted  int            `json:"accepted"`
+	Limit     int            `json:"limit"`
+	Stage     rules.Stage    `json:"stage"`
+	Tickets   []rules.Ticket `json:"tickets,omitempty"`
+	Planned   []plannedRule  `json:"planned"`
+}
+
+// plannedRule is when a rule fires next if the event stays as it is
+type plannedRule struct {
+	Rule string    `json:"rule"`
+	At   time.Time `json:"at"`
+}
+
+// dashboardAttendance is the attendance of an ended event
+type dashboardAttendance struct {
+	Title     string    `json:"title"`
+	StartedAt time.Time `json:"started_at"`
+	Accepted  int       `json:"accepted"`
+	Limit     int       `json:"limit"`
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head><meta charset="utf-8"><title>nfug-eventbot</title></head>
+<body>
+<h1>nfug-eventbot</h1>
+<p>{{.GeneratedAt.Format "2006/01/02 15:04"}} 時点</p>
+<h2>connpass</h2>
+<ul>
+<li>最終取得: {{if .LastSync.IsZero}}なし{{else}}{{.LastSync.Format "2006/01/02 15:04"}}{{end}}</li>
+{{if not .MaintenanceSince.IsZero}}<li>メンテナンス中: {{.MaintenanceSince.Format "2006/01/02 15:04"}} から</li>{{end}}
+<li>直近の取得失敗: {{.ConnpassFailures}} 回</li>
+</ul>
+<h2>これからのイベント</h2>
+{{range .Upcoming}}<h3><a href="{{.URL}}">{{.Title}}</a></h3>
+<p>{{.StartedAt.Format "2006/01/02 15:04"}} / 参加者 {{.Accepted}}/{{.Limit}}人 / {{.Stage}}</p>
+{{if .Tickets}}<p>{{range $i, $t := .Tickets}}{{if $i}}、{{end}}{{$t.Name}} {{$t.Accepted}}/{{$t.Limit}}人{{if $t.Waiting}} (補欠{{$t.Waiting}}人){{end}}{{end}}</p>{{end}}
+<ul>{{range .Planned}}<li>{{.At.Format "01/02 15:04"}} ごろ {{.Rule}}</li>{{else}}<li>予定している通知はありません</li>{{end}}</ul>
+{{else}}<p>ありません</p>{{end}}
+<h2>通知</h2>
+<ul>
+<li>静かな時間帯で保留中: {{.Deferred}}件</li>
+<li>送れなかった通知: {{.DeadLetters}}件</li>
+<li>承認待ち: {{.PendingApprovals}}件</li>
+</ul>
+<h2>最近の失敗</h2>
+<ul>{{range .Errors}}<li>{{.CreatedAt.Format "01/02 15:04"}} {{.Type}} → {{.Channel}}: {{.Detail}}</li>{{else}}<li>ありません</li>{{end}}</ul>
+<h2>参加者の推移</h2>
+<ul>{{range .Trend}}<li>{{.StartedAt.Format "2006/01/02"}} {{.Title}}: {{.Accepted}}/{{.Limit}}人</li>{{end}}</ul>
+</body>
+</html>
+`))
+
+// plannedRules returns when the rules fire next for the event within the horizon, assuming nothing changes on connpass
+func plannedRules(ctx context.Context, event Event, now time.Time) []plannedRule {
+	e := ruleEvent(ctx, event, loadSnapshot(ctx, event))
+	current := rules.Stage(loadLifecycle(ctx, event.URL).Stage)
+
+	var planned []plannedRule
+	for _, rule := range rules.Rules {
+		rule = currentSettings(ctx).rule(rule)
+		if !tagsAllow(ctx, e.Tags, rule) {
+			continue
+		}
+		lastSentAt := loadSent(ctx, rule.Name, event.URL).LastSentAt
+		for t := now.Truncate(dashboardStep).Add(dashboardStep); t.Before(now.Add(dashboardHorizon)); t = t.Add(dashboardStep) {
+			e.Stage = rules.Advance(current, e, t)
+			if !rule.AfterEnd && !t.Before(event.EndedAt) {
+				break
+			}
+			if rule.InStage(e) && rule.Predicate(e, t) && rule.Due(e, lastSentAt, t) {
+				planned = append(planned, plannedRule{Rule: rule.Name, At: t})
+				break
+			}
+		}
+	}
+
+	sort.Slice(planned, func(i, j int) bool { return planned[i].At.Before(planned[j].At) })
+	return planned
+}
+
+func buildDashboard(ctx context.Context, now time.Time) dashboard {
+	cache := loadConnpassCache(ctx)
+	d := dashboard{
+		GeneratedAt:      now,
+		LastSync:         cache.FetchedAt,
+		MaintenanceSince: cache.MaintenanceSince,
+		Upcoming:         []dashboardEvent{},
+		Errors:           []AuditEntry{},
+		Trend:            []dashboardAttendance{},
+	}
+
+	if events, err := parseEvents(cache.Body); err == nil {
+		for _, event := range events {
+			if isEnded(event.EndedAt) {
+				continue
+			}
+			d.Upcoming = append(d.Upcoming, dashboardEvent{
+				Title:     event.Title,
+				URL:       event.URL,
+				StartedAt: event.StartedAt,
+				Accepted:  event.Accepted,
+				Limit:     event.Limit,
+				Stage:     rules.Stage(loadLifecycle(ctx, event.URL).Stage),
+				Tickets:   loadTickets(ctx, event),
+				Planned:   plannedRules(ctx, event, now),
+			})
+		}
+		sort.Slice(d.Upcoming, func(i, j int) bool { return d.Upcoming[i].StartedAt.Before(d.Upcoming[j].StartedAt) })
+	}
+
+	var err error
+	if d.ConnpassFailures, err = datastore.NewQuery(connpassFailureKind).Filter("OccurredAt >", now.Add(-currentSettings(ctx).connpassErrorWindow)).Count(ctx); err != nil {
+		log.Errorf(ctx, "dashboard connpass failures: %v", err)
+	}
+	if d.Deferred, err = datastore.NewQuery(deferredKind).Count(ctx); err != nil {
+		log.Errorf(ctx, "dashboard deferred: %v", err)
+	}
+	if d.DeadLetters, err = datastore.NewQuery(deadLetterKind).Count(ctx); err != nil {
+		log.Errorf(ctx, "dashboard dead letters: %v", err)
+	}
+	if d.PendingApprovals, err = datastore.NewQuery(approvalKind).Filter("Status =", approvalPending).Count(ctx); err != nil {
+		log.Errorf(ctx, "dashboard approvals: %v", err)
+	}
+
+	// a single filter keeps the query free of a composite index
+	var entries []AuditEntry
+	if _, err := datastore.NewQuery(auditKind).Filter("CreatedAt >", now.AddDate(0, 0, -7)).GetAll(ctx, &entries); err != nil {
+		log.Errorf(ctx, "dashboard audit: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+	for _, entry := range entries {
+		if entry.Action == auditFailed && len(d.Errors) < dashboardErrors {
+			d.Errors = append(d.Errors, entry)
+		}
+	}
+
+	var ended []ArchivedEvent
+	for _, archived := range archivedEvents(ctx, now.AddDate(-1, 0, 0), now) {
+		if isEnded(archived.EndedAt) {
+			ended = append(ended, archived)
+		}
+	}
+	if len(ended) > dashboardTrend {
+		ended = ended[len(ended)-dashboardTrend:]
+	}
+	for _, archived := range ended {
+		d.Trend = append(d.Trend, dashboardAttendance{Title: archived.Title, StartedAt: archived.StartedAt, Accepted: archived.Accepted, Limit: archived.Limit})
+	}
+
+	return d
+}
+
+// handleDashboard serves /dashboard?community={name}, as JSON with ?format=json
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleViewer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, err := withCommunity(appengine.NewContext(r), r.URL.Query().Get("community"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	d := buildDashboard(ctx, time.Now())
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, d); err != nil {
+		log.Errorf(ctx, "dashboard: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const deadLetterKind = "DeadLetter"
+
+// DeadLetter is a notification that could not be sent after retries
+type DeadLetter struct {
+	Type       string
+	Channel    string
+	Text       string `datastore:",noindex"`
+	BlocksJSON string `datastore:",noindex"`
+	EventJSON  string `datastore:",noindex"`
+	Error      string `datastore:",noindex"`
+	CreatedAt  time.Time
+}
+
+// deadLetterView is a dead letter listed by /admin/deadletter
+type deadLetterView struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	Channel   string    `json:"channel"`
+	Text      string    `json:"text"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func deadLetter(ctx context.Context, kind string, event Event, channel, text string, blocks []interface{}, cause error) {
+	eventJSON, _ := json.Marshal(event)
+	letter := DeadLetter{
+		Type:      kind,
+		Channel:   channel,
+		Text:      text,
+		EventJSON: string(eventJSON),
+		Error:     cause.Error(),
+		CreatedAt: time.Now(),
+	}
+	if len(blocks) > 0 {
+		blocksJSON, _ := json.Marshal(blocks)
+		letter.BlocksJSON = string(blocksJSON)
+	}
+
+	key := datastore.NewIncompleteKey(ctx, deadLetterKind, nil)
+	if _, err := datastore.Put(ctx, key, &letter); err != nil {
+		log.Errorf(ctx, "deadletter put: %v", err)
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:   auditFailed,
+		Type:     kind,
+		Channel:  channel,
+		EventURL: event.URL,
+		Text:     text,
+		Detail:   cause.Error(),
+	})
+}
+
+// redeliver sends the dead letter again and removes it once it is posted
+func redeliver(ctx context.Context, id int64) error {
+	key := datastore.NewKey(ctx, deadLetterKind, "", id, nil)
+
+	var letter DeadLetter
+	if err := datastore.Get(ctx, key, &letter); err != nil {
+		return err
+	}
+
+	var event Event
+	json.Unmarshal([]byte(letter.EventJSON), &event)
+
+	var blocks []interface{}
+	if letter.BlocksJSON != "" {
+		json.Unmarshal([]byte(letter.BlocksJSON), &blocks)
+	}
+
+	posted, err := sendSlack(ctx, letter.Type, letter.Channel, letter.Text, blocks...)
+	if err != nil {
+		return err
+	}
+	delivered(ctx, letter.Type, event, letter.Channel, letter.Text, posted)
+
+	return datastore.Delete(ctx, key)
+}
+
+// handleDeadLetter lists the dead letters, or redelivers one with POST ?id=
+func handleDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleViewer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := appengine.NewContext(r)
+
+	if r.Method == http.MethodPost {
+		if !hasRole(r, roleOrganizer) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		if err := redeliver(ctx, id); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintln(w, "redelivered")
+		return
+	}
+
+	var letters []DeadLetter
+	keys, err := datastore.NewQuery(deadLetterKind).Order("CreatedAt").GetAll(ctx, &letters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	views := []deadLetterView{}
+	for i, letter := range letters {
+		views = append(views, deadLetterView{
+			ID:        keys[i].IntID(),
+			Type:      letter.Type,
+			Channel:   letter.Channel,
+			Text:      letter.Text,
+			Error:     letter.Error,
+			CreatedAt: letter.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
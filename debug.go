@@ -0,0 +1,77 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+)
+
+const (
+	debugPlanned     = 5
+	textDebugNoRole  = "デバッグ情報を見る権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+	textDebugVersion = "バージョン: %s\n設定: %s (%s に読み込み)\n"
+	textDebugConfig  = "設定の問題: %s\n"
+	textDebugSync    = "connpass の最終取得: %s / 直近の取得失敗: %d回\n"
+	textDebugPaused  = "connpass のメンテナンス中: %s から\n"
+	textDebugQueue   = "送れなかった通知: %d件 / 静かな時間帯で保留中: %d件 / 承認待ち: %d件\n"
+	textDebugNext    = "次の通知:\n"
+	textDebugPlanned = "• %s ごろ %s『%s』\n"
+	textDebugNone    = "予定している通知はありません\n"
+)
+
+// commandDebug handles "/nfug debug", the bot's view of the world for "the bot didn't post" questions
+func commandDebug(ctx context.Context, userID string) string {
+	if userRole(userID) < roleOrganizer {
+		return textDebugNoRole
+	}
+
+	now := time.Now()
+	d := buildDashboard(ctx, now)
+	s := currentSettings(ctx)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, textDebugVersion, appengine.VersionID(ctx), s.hash, s.loadedAt.Format("2006/01/02 15:04"))
+	if len(configErrors) > 0 {
+		fmt.Fprintf(&b, textDebugConfig, strings.Join(configErrors, ", "))
+	}
+
+	lastSync := "なし"
+	if !d.LastSync.IsZero() {
+		lastSync = d.LastSync.Format("2006/01/02 15:04")
+	}
+	fmt.Fprintf(&b, textDebugSync, lastSync, d.ConnpassFailures)
+	if !d.MaintenanceSince.IsZero() {
+		fmt.Fprintf(&b, textDebugPaused, d.MaintenanceSince.Format("2006/01/02 15:04"))
+	}
+	fmt.Fprintf(&b, textDebugQueue, d.DeadLetters, d.Deferred, d.PendingApprovals)
+
+	// the firings of all upcoming events in the order they happen
+	type firing struct {
+		plannedRule
+		title string
+	}
+	var firings []firing
+	for _, event := range d.Upcoming {
+		for _, planned := range event.Planned {
+			firings = append(firings, firing{planned, event.Title})
+		}
+	}
+	sort.Slice(firings, func(i, j int) bool { return firings[i].At.Before(firings[j].At) })
+	if len(firings) > debugPlanned {
+		firings = firings[:debugPlanned]
+	}
+
+	b.WriteString(textDebugNext)
+	for _, f := range firings {
+		fmt.Fprintf(&b, textDebugPlanned, f.At.Format("01/02 15:04"), f.Rule, f.title)
+	}
+	if len(firings) == 0 {
+		b.WriteString(textDebugNone)
+	}
+
+	return b.String()
+}
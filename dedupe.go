@@ -0,0 +1,100 @@
+package slackbot
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const sentKind = "SentNotification"
+
+// SentNotification records that a rule fired for an event, keyed by rule and event ID
+type SentNotification struct {
+	Rule       string
+	EventURL   string
+	Count      int
+	LastSentAt time.Time
+	// Slot is the logical slot the rule last fired in, see claimSlot
+	Slot string
+}
+
+func sentKey(ctx context.Context, rule, eventURL string) *datastore.Key {
+	return datastore.NewKey(ctx, sentKind, rule+" "+eventKeyName(eventURL), 0, nil)
+}
+
+// legacySentKey is the key of records written before events were keyed by their ID.
+// Records of URLs that weren't canonical are moved by migrateEventKeys instead.
+func legacySentKey(ctx context.Context, rule, eventURL string) *datastore.Key {
+	return datastore.NewKey(ctx, sentKind, rule+" "+eventURL, 0, nil)
+}
+
+func loadSent(ctx context.Context, rule, eventURL string) SentNotification {
+	var sent SentNotification
+	err := datastore.Get(ctx, sentKey(ctx, rule, eventURL), &sent)
+	if err == datastore.ErrNoSuchEntity {
+		err = datastore.Get(ctx, legacySentKey(ctx, rule, eventURL), &sent)
+	}
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "sent get %s %s: %v", rule, eventURL, err)
+	}
+
+	return sent
+}
+
+func markSent(ctx context.Context, rule, eventURL string, now time.Time) {
+	sent := loadSent(ctx, rule, eventURL)
+	sent.Rule = rule
+	sent.EventURL = eventURL
+	sent.Count++
+	sent.LastSentAt = now
+
+	if _, err := datastore.Put(ctx, sentKey(ctx, rule, eventURL), &sent); err != nil {
+		log.Errorf(ctx, "sent put %s %s: %v", rule, eventURL, err)
+	}
+}
+
+// slotOf returns the logical slot of now. Rules fire at most once a day per event,
+// so the date keeps them idempotent however often cron runs.
+func slotOf(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// claimSlot records that the rule fires for the event in the slot of now.
+// It reports false when the slot was already claimed, e.g. by an earlier or overlapping cron run.
+func claimSlot(ctx context.Context, rule, eventURL string, now time.Time) bool {
+	key := sentKey(ctx, rule, eventURL)
+	slot := slotOf(now)
+
+	claimed := false
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var sent SentNotification
+		err := datastore.Get(tc, key, &sent)
+		if err == datastore.ErrNoSuchEntity {
+			// the counts so far of a legacy record carry over, outside the transaction as it is another entity group
+			err = datastore.Get(ctx, legacySentKey(ctx, rule, eventURL), &sent)
+		}
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if sent.Slot == slot {
+			return nil
+		}
+
+		sent.Rule = rule
+		sent.EventURL = eventURL
+		sent.Slot = slot
+		if _, err := datastore.Put(tc, key, &sent); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "sent slot %s %s: %v", rule, eventURL, err)
+		return false
+	}
+
+	return claimed
+}
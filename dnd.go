@@ -0,0 +1,61 @@
+package slackbot
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dndInfo is the response of dnd.info
+// ref: https://api.slack.com/methods/dnd.info
+type dndInfo struct {
+	DNDEnabled     bool  `json:"dnd_enabled"`
+	NextDNDStartTS int64 `json:"next_dnd_start_ts"`
+	NextDNDEndTS   int64 `json:"next_dnd_end_ts"`
+	SnoozeEnabled  bool  `json:"snooze_enabled"`
+	SnoozeEndtime  int64 `json:"snooze_endtime"`
+}
+
+// isUserID reports whether the channel is a user ID, i.e. the message is a DM
+func isUserID(channel string) bool {
+	return strings.HasPrefix(channel, "U") || strings.HasPrefix(channel, "W")
+}
+
+// dndUntil returns when the do-not-disturb window of the user ends, or false when the user can be notified now
+func dndUntil(ctx context.Context, userID string, now time.Time) (time.Time, bool) {
+	var info dndInfo
+	if err := callSlackAPIGet(ctx, "dnd.info", url.Values{"user": {userID}}, &info); err != nil {
+		return time.Time{}, false
+	}
+
+	var until time.Time
+	if info.SnoozeEnabled {
+		until = time.Unix(info.SnoozeEndtime, 0)
+	}
+	if info.DNDEnabled {
+		start, end := time.Unix(info.NextDNDStartTS, 0), time.Unix(info.NextDNDEndTS, 0)
+		if !now.Before(start) && now.Before(end) && end.After(until) {
+			until = end
+		}
+	}
+
+	return until, until.After(now)
+}
+
+// scheduleMessage posts the message at postAt
+// ref: https://api.slack.com/methods/chat.scheduleMessage
+func scheduleMessage(ctx context.Context, channel, text string, postAt time.Time, blocks ...interface{}) error {
+	params := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+		"post_at": strconv.FormatInt(postAt.Unix(), 10),
+	}
+	if len(blocks) > 0 {
+		params["blocks"] = blocks
+	}
+
+	_, err := callSlackAPI(ctx, "chat.scheduleMessage", params)
+	return err
+}
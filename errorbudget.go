@@ -0,0 +1,59 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	connpassFailureKind = "ConnpassFailure"
+	// failures are kept this long, well beyond any sensible window
+	connpassFailureRetention = 24 * time.Hour
+	textConnpassErrors       = "connpass の取得が直近 %s で %d 回を超えて失敗しています"
+)
+
+// connpassFailures counts failed connpass requests, reported by /healthz
+var connpassFailures int64
+
+// ConnpassFailure is a failed connpass request, counted over the error window
+type ConnpassFailure struct {
+	Reason     string `datastore:",noindex"`
+	OccurredAt time.Time
+}
+
+// recordConnpassFailure keeps the failure and alerts only when the failures within
+// connpass_error_window exceed connpass_error_threshold, so that isolated blips stay in the log
+func recordConnpassFailure(ctx context.Context, w http.ResponseWriter, cause error, now time.Time) {
+	atomic.AddInt64(&connpassFailures, 1)
+
+	failure := ConnpassFailure{Reason: cause.Error(), OccurredAt: now}
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, connpassFailureKind, nil), &failure); err != nil {
+		log.Errorf(ctx, "connpass failure put: %v", err)
+		return
+	}
+
+	s := currentSettings(ctx)
+	count, err := datastore.NewQuery(connpassFailureKind).Filter("OccurredAt >", now.Add(-s.connpassErrorWindow)).Count(ctx)
+	if err != nil {
+		log.Errorf(ctx, "connpass failure count: %v", err)
+		return
+	}
+	if count > s.ConnpassErrorThreshold {
+		alert(ctx, w, fmt.Sprintf(textConnpassErrors, s.connpassErrorWindow, s.ConnpassErrorThreshold), now)
+	}
+
+	keys, err := datastore.NewQuery(connpassFailureKind).Filter("OccurredAt <", now.Add(-connpassFailureRetention)).KeysOnly().GetAll(ctx, nil)
+	if err != nil {
+		log.Errorf(ctx, "connpass failure query: %v", err)
+		return
+	}
+	if err := datastore.DeleteMulti(ctx, keys); err != nil {
+		log.Errorf(ctx, "connpass failure delete: %v", err)
+	}
+}
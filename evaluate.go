@@ -0,0 +1,208 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/log"
+)
+
+// enrichers add data that is expensive to fetch, only for fired rules
+var enrichers = map[string]func(ctx context.Context, event Event, e *rules.Event){
+	rules.TwoWeeksBefore: func(ctx context.Context, event Event, e *rules.Event) {
+		if previous, ok := previousEvent(ctx, event); ok {
+			e.PreviousAccepted = previous.Accepted
+		}
+	},
+	rules.OneWeekBefore: func(ctx context.Context, event Event, e *rules.Event) {
+		e.Headcount = headcount(ctx, event)
+		e.Forecast = forecast(ctx, event)
+	},
+	rules.TwoDaysBefore: func(ctx context.Context, event Event, e *rules.Event) {
+		e.Headcount = headcount(ctx, event)
+	},
+	rules.Promotion: func(ctx context.Context, event Event, e *rules.Event) {
+		e.AcceptedDelta = acceptedSince(ctx, event, loadSent(ctx, rules.Promotion, event.URL).LastSentAt)
+	},
+	rules.Participants: func(ctx context.Context, event Event, e *rules.Event) {
+		e.Composition = composition(ctx, event)
+	},
+	rules.Start: func(ctx context.Context, event Event, e *rules.Event) {
+		if seriesConfigFor(ctx, event).WelcomeFirstTimers {
+			e.FirstTimers = countFirstTimers(ctx, event)
+		}
+	},
+	rules.MorningOf: func(ctx context.Context, event Event, e *rules.Event) {
+		e.Weather = weather(ctx, event)
+		_, e.Streamed = loadStreamingURL(ctx, event.URL)
+	},
+	rules.PhotoSummary: func(ctx context.Context, event Event, e *rules.Event) {
+		e.Photos = photoSummary(ctx, event)
+	},
+	rules.NextDay: func(ctx context.Context, event Event, e *rules.Event) {
+		e.HashtagActivity = hashtagActivity(ctx, event, e.Hashtag)
+	},
+}
+
+// actions change external services for fired rules, so they are skipped by previews
+var actions = map[string]func(ctx context.Context, event Event, e *rules.Event){
+	rules.OneWeekBefore: func(ctx context.Context, event Event, e *rules.Event) {
+		if isVenueUndecided(event.Place) {
+			cardURL, err := createVenueCard(ctx, event)
+			if err != nil {
+				log.Errorf(ctx, "trello %s: %v", event.URL, err)
+			}
+			e.TaskURL = cardURL
+		}
+	},
+}
+
+// renderRule renders the text and blocks of the rule for the event
+func renderRule(ctx context.Context, rule rules.Rule, event Event, e rules.Event) (string, []interface{}, error) {
+	bottext, err := rule.Render(e)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var blocks []interface{}
+	switch {
+	case rule.Announcement:
+		blocks = announcementBlocks(bottext, getEventImageURL(ctx, event.URL), event.Title)
+		blocks = append(blocks, calendarBlock(ctx, event))
+	case rule.QRCode:
+		blocks = qrCodeBlocks(ctx, bottext, event)
+	}
+
+	return bottext, blocks, nil
+}
+
+// ruleEvent builds what rules look at from the connpass event and its snapshot
+func ruleEvent(ctx context.Context, event Event, snapshot EventSnapshot) rules.Event {
+	series := seriesConfigFor(ctx, event)
+	proposals := talkProposals(ctx, event.URL)
+	partner, _ := partnerFor(ctx, event)
+	venue, known := venueFor(ctx, event)
+	usual := 0
+	if known {
+		usual = usualLimit(ctx, event)
+	}
+
+	tags := eventTags(ctx, event)
+
+	return rules.Event{
+		Title:                event.Title,
+		URL:                  event.URL,
+		Place:                event.Place,
+		StartedAt:            event.StartedAt,
+		EndedAt:              event.EndedAt,
+		Limit:                event.Limit,
+		Accepted:             event.Accepted,
+		Waiting:              event.Waiting,
+		RegistrationOpenedAt: snapshot.RegistrationOpenedAt,
+		Hashtag:              series.Hashtag,
+		HashtagURL:           hashtagSearchURL(series.Hashtag),
+		TweetURL:             tweetIntentURL(event.Title, event.URL, series.Hashtag, series.Twitter),
+		Twitter:              series.Twitter,
+		IntroChannel:         series.IntroChannel,
+		Talks:                len(proposals),
+		Slots:                currentSettings(ctx).ProgramSlots,
+		RegularHour:          currentSettings(ctx).RegularHour,
+		QuietRatio:           currentSettings(ctx).QuietEventRatio,
+		CapacityRatio:        currentSettings(ctx).CapacityWarningRatio,
+		WaitlistRatio:        currentSettings(ctx).WaitlistEscalationRatio,
+		Lineup:               formatLineup(proposals),
+		Partner:              partner.Name,
+		AccessNotes:          venue.notes(),
+		VenueCapacity:        venue.Capacity,
+		UsualLimit:           usual,
+		AnnounceGrace:        currentSettings(ctx).announceGrace,
+		Tags:                 tags,
+		Payment:              tagsPayment(ctx, tags),
+		ParticipantSummary:   currentSettings(ctx).ParticipantSummary,
+		MorningHour:          currentSettings(ctx).MorningHour,
+		DoorOpen:             currentSettings(ctx).doorOpen,
+		Owner:                event.Owner,
+		Tickets:              loadTickets(ctx, event),
+		WatchedTickets:       watchedTickets(ctx),
+	}
+}
+
+// evaluateRules posts the notifications of all rules firing for the event and returns their names
+func evaluateRules(ctx context.Context, w http.ResponseWriter, event Event, snapshot EventSnapshot, now time.Time) []string {
+	var fired []string
+	series := seriesConfigFor(ctx, event)
+	ctx = withTeam(ctx, series.Team)
+	e := ruleEvent(ctx, event, snapshot)
+	e.TimeToEvent = rules.TimeToEvent(event.StartedAt, event.EndedAt, now)
+	e.Stage = advanceLifecycle(ctx, event, e, now)
+
+	for _, rule := range rules.Rules {
+		rule = currentSettings(ctx).rule(rule)
+		// past events may be surfaced by a stale API response
+		if !rule.AfterEnd && isEnded(event.EndedAt) {
+			continue
+		}
+		if !rule.InStage(e) || !tagsAllow(ctx, e.Tags, rule) || !rule.Predicate(e, now) {
+			continue
+		}
+		if !rule.Due(e, loadSent(ctx, rule.Name, event.URL).LastSentAt, now) {
+			continue
+		}
+
+		// claimed before enrichers and actions, so an overlapping cron run doesn't create Trello cards or count variants twice
+		if !claimSlot(ctx, rule.Name, event.URL, now) {
+			continue
+		}
+
+		if enrich, ok := enrichers[rule.Name]; ok {
+			enrich(ctx, event, &e)
+		}
+		if action, ok := actions[rule.Name]; ok {
+			action(ctx, event, &e)
+		}
+
+		variant := nextVariant(ctx, rule.Name)
+		rendered := e
+		rendered.URL = shorten(ctx, event.URL, variantKind(rule.Name, variant))
+		bottext, blocks, err := renderRule(ctx, currentSettings(ctx).variantRule(rule, variant), event, rendered)
+		if err != nil {
+			log.Errorf(ctx, "rule %s: %v", rule.Name, err)
+			continue
+		}
+
+		channel := series.channel(rule.Channel)
+		// organizer tasks are sent as DMs to the assigned organizer, otherwise #manage is asked who takes the event.
+		// DMs and buttons need the Web API.
+		if rule.Channel == rules.Manage && botToken(ctx) != "" {
+			if organizer, ok := loadOrganizer(ctx, event.URL); ok {
+				channel = organizer.UserID
+			} else {
+				blocks = claimBlocks(bottext, event, blocks)
+			}
+		}
+
+		if needsApproval(ctx, channel, bottext) {
+			requestApproval(ctx, w, rule.Name, event, channel, bottext, blocks)
+		} else {
+			notify(ctx, w, rule.Name, event, channel, bottext, blocks...)
+			if rule.Announcement {
+				crossPost(ctx, w, event, bottext, blocks...)
+				fanOutChannels(ctx, w, rule.Name, event, bottext, blocks...)
+			}
+		}
+		markSent(ctx, rule.Name, event.URL, now)
+		countVariant(ctx, rule.Name, variant)
+		fired = append(fired, rule.Name)
+	}
+
+	return fired
+}
+
+// handleRules lists the active rules
+func handleRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules.Rules)
+}
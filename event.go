@@ -0,0 +1,248 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventIDRe finds the connpass event ID in an event URL like https://nfug.connpass.com/event/12345/
+var eventIDRe = regexp.MustCompile(`/event/(\d+)`)
+
+// connpassSeries is the series part of connpassEvent
+type connpassSeries struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// connpassEvent is an event as returned by the connpass event search API
+// ref: https://connpass.com/about/api/
+type connpassEvent struct {
+	EventID          int            `json:"event_id"`
+	Title            string         `json:"title"`
+	EventURL         string         `json:"event_url"`
+	HashTag          string         `json:"hash_tag"`
+	StartedAt        time.Time      `json:"started_at"`
+	EndedAt          time.Time      `json:"ended_at"`
+	Limit            int            `json:"limit"`
+	Address          string         `json:"address"`
+	Place            string         `json:"place"`
+	Lat              string         `json:"lat"`
+	Lon              string         `json:"lon"`
+	OwnerNickname    string         `json:"owner_nickname"`
+	OwnerDisplayName string         `json:"owner_display_name"`
+	Accepted         int            `json:"accepted"`
+	Waiting          int            `json:"waiting"`
+	Series           connpassSeries `json:"series"`
+}
+
+// Event is the event all features work with, independent of the connpass API shape
+type Event struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	Place       string    `json:"place"`
+	Address     string    `json:"address"`
+	Lat         string    `json:"lat"`
+	Lon         string    `json:"lon"`
+	Limit       int       `json:"limit"`
+	Accepted    int       `json:"accepted"`
+	Waiting     int       `json:"waiting"`
+	Hashtag     string    `json:"hashtag"`
+	Online      bool      `json:"online"`
+	Owner       string    `json:"owner"`
+	SeriesID    int       `json:"series_id"`
+	SeriesTitle string    `json:"series_title"`
+	// SeriesIDs are all the series the event is listed under, SeriesID being the first
+	SeriesIDs []int `json:"series_ids"`
+	// RawURL is event_url as connpass returned it, which records were keyed by before URLs were canonicalized
+	RawURL string `json:"raw_url,omitempty"`
+}
+
+// canonicalEventURL normalizes the scheme, the host case, the trailing slash and the query of an event URL,
+// which differ between API responses and links pasted by members
+func canonicalEventURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = "https"
+	u.Host = strings.ToLower(u.Host)
+	u.RawQuery = ""
+	u.Fragment = ""
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u.String()
+}
+
+// eventIDOf returns the connpass event ID of an event URL, or of the ID itself
+func eventIDOf(raw string) (int, bool) {
+	raw = strings.TrimSpace(raw)
+	if id, err := strconv.Atoi(raw); err == nil && id > 0 {
+		return id, true
+	}
+	matches := eventIDRe.FindStringSubmatch(raw)
+	if matches == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(matches[1])
+	return id, err == nil
+}
+
+// eventKeyName identifies an event in Datastore keys and admin APIs: its connpass event ID,
+// or the canonical URL when the URL has none
+func eventKeyName(eventURL string) string {
+	if id, ok := eventIDOf(eventURL); ok {
+		return strconv.Itoa(id)
+	}
+	return canonicalEventURL(eventURL)
+}
+
+// onlineWords mark a place or address as online
+var onlineWords = []string{"オンライン", "online", "Online", "Zoom", "YouTube", "Discord"}
+
+func isOnlinePlace(place, address string) bool {
+	for _, word := range onlineWords {
+		if strings.Contains(place, word) || strings.Contains(address, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// event maps the connpass JSON to Event, with times in the configured location
+func (c connpassEvent) event() Event {
+	owner := c.OwnerDisplayName
+	if owner == "" {
+		owner = c.OwnerNickname
+	}
+
+	return Event{
+		ID:          c.EventID,
+		Title:       c.Title,
+		URL:         canonicalEventURL(c.EventURL),
+		RawURL:      c.EventURL,
+		StartedAt:   c.StartedAt.In(time.Local),
+		EndedAt:     c.EndedAt.In(time.Local),
+		Place:       c.Place,
+		Address:     c.Address,
+		Lat:         c.Lat,
+		Lon:         c.Lon,
+		Limit:       c.Limit,
+		Accepted:    c.Accepted,
+		Waiting:     c.Waiting,
+		Hashtag:     c.HashTag,
+		Online:      isOnlinePlace(c.Place, c.Address),
+		Owner:       owner,
+		SeriesID:    c.Series.ID,
+		SeriesTitle: c.Series.Title,
+		SeriesIDs:   []int{c.Series.ID},
+	}
+}
+
+// schemaExcerptLength is how much of an invalid response is shown in errors
+const schemaExcerptLength = 200
+
+// schemaError is a connpass response that doesn't match the expected schema
+type schemaError struct {
+	Reason  string
+	Excerpt string
+}
+
+func (e *schemaError) Error() string {
+	return fmt.Sprintf("connpass schema: %s (body: %q)", e.Reason, e.Excerpt)
+}
+
+func newSchemaError(rawText []byte, format string, args ...interface{}) *schemaError {
+	excerpt := []rune(string(rawText))
+	if len(excerpt) > schemaExcerptLength {
+		excerpt = excerpt[:schemaExcerptLength]
+	}
+	return &schemaError{Reason: fmt.Sprintf(format, args...), Excerpt: string(excerpt)}
+}
+
+// validate checks the fields every feature relies on
+func (c connpassEvent) validate() error {
+	var missing []string
+	if c.EventID == 0 {
+		missing = append(missing, "event_id")
+	}
+	if c.Title == "" {
+		missing = append(missing, "title")
+	}
+	if c.EventURL == "" {
+		missing = append(missing, "event_url")
+	}
+	if c.StartedAt.IsZero() {
+		missing = append(missing, "started_at")
+	}
+	if c.EndedAt.IsZero() {
+		missing = append(missing, "ended_at")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// parseEvents maps the connpass API response to events.
+// Unknown fields are ignored, but missing required fields are reported as a schemaError.
+func parseEvents(rawText []byte) ([]Event, error) {
+	var results struct {
+		Events *[]connpassEvent `json:"events"`
+	}
+	if err := json.Unmarshal(rawText, &results); err != nil {
+		return nil, newSchemaError(rawText, "%v", err)
+	}
+	if results.Events == nil {
+		return nil, newSchemaError(rawText, "missing events")
+	}
+
+	events := make([]Event, 0, len(*results.Events))
+	for i, c := range *results.Events {
+		if err := c.validate(); err != nil {
+			return nil, newSchemaError(rawText, "events[%d]: %v", i, err)
+		}
+		events = append(events, c.event())
+	}
+	return dedupeEvents(events), nil
+}
+
+// dedupeEvents merges events listed more than once, e.g. co-hosted under both series,
+// so that they are processed once. The series are merged into SeriesIDs.
+func dedupeEvents(events []Event) []Event {
+	index := map[string]int{}
+	deduped := events[:0]
+	for _, event := range events {
+		i, seen := index[event.URL]
+		if !seen {
+			index[event.URL] = len(deduped)
+			deduped = append(deduped, event)
+			continue
+		}
+		for _, id := range event.SeriesIDs {
+			if !deduped[i].inSeries(id) {
+				deduped[i].SeriesIDs = append(deduped[i].SeriesIDs, id)
+			}
+		}
+	}
+	return deduped
+}
+
+// inSeries reports whether the event is listed under the series
+func (e Event) inSeries(id int) bool {
+	for _, seriesID := range e.SeriesIDs {
+		if seriesID == id {
+			return true
+		}
+	}
+	return e.SeriesID == id
+}
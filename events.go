@@ -0,0 +1,184 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	textWelcome          = "NFUG の Slack へようこそ！お知らせは %s に流れます。イベントの感想はハッシュタグ #%s でどうぞ！"
+	textWelcomeNextEvent = "\n次回のイベントは『%s』です。\n日時: %s\n会場: %s\n<%s>"
+	receivedEventKind    = "ReceivedEvent"
+)
+
+// ReceivedEvent records an Events API callback that was handled, keyed by event ID,
+// so that the redeliveries of Slack aren't handled again
+type ReceivedEvent struct {
+	Type       string
+	RetryNum   string
+	ReceivedAt time.Time
+}
+
+// eventCallback is the envelope of the Events API
+// ref: https://api.slack.com/apis/connections/events-api#callback-field
+type eventCallback struct {
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	TeamID    string          `json:"team_id"`
+	EventID   string          `json:"event_id"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// slackEvent is the common part of inner events
+type slackEvent struct {
+	Type string `json:"type"`
+}
+
+// teamJoinEvent ref: https://api.slack.com/events/team_join
+type teamJoinEvent struct {
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+}
+
+// messageEvent ref: https://api.slack.com/events/message
+type messageEvent struct {
+	Subtype  string        `json:"subtype"`
+	BotID    string        `json:"bot_id"`
+	User     string        `json:"user"`
+	Channel  string        `json:"channel"`
+	Text     string        `json:"text"`
+	TS       string        `json:"ts"`
+	ThreadTS string        `json:"thread_ts"`
+	Files    []messageFile `json:"files"`
+}
+
+// handleEvents receives the Events API callbacks
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	body, err := verifySlackRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var callback eventCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// ref: https://api.slack.com/events/url_verification
+	if callback.Type == "url_verification" {
+		fmt.Fprint(w, callback.Challenge)
+		return
+	}
+
+	var event slackEvent
+	if err := json.Unmarshal(callback.Event, &event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx = forTeam(ctx, callback.TeamID)
+	// Slack redelivers the callback when the first delivery was slow or failed,
+	// which would welcome a member or answer a question again
+	// ref: https://api.slack.com/apis/connections/events-api#retries
+	if !claimEvent(ctx, callback.EventID, event.Type, r.Header.Get("X-Slack-Retry-Num")) {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch event.Type {
+	case "team_join":
+		var joined teamJoinEvent
+		if err := json.Unmarshal(callback.Event, &joined); err == nil {
+			welcome(ctx, joined.User.ID)
+		}
+	case "message":
+		var message messageEvent
+		if err := json.Unmarshal(callback.Event, &message); err == nil && message.BotID == "" {
+			switch message.Subtype {
+			case "":
+				answerVenue(ctx, message)
+				collectPhotos(ctx, message)
+			case "file_share":
+				collectPhotos(ctx, message)
+			}
+		}
+	case "reaction_added":
+		var reaction reactionAddedEvent
+		if err := json.Unmarshal(callback.Event, &reaction); err == nil {
+			acknowledgeTask(ctx, reaction)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// claimEvent records the event ID in a transaction and reports false when the event was already handled.
+// Callbacks without an ID are handled, as there is nothing to tell their redeliveries apart.
+func claimEvent(ctx context.Context, eventID, eventType, retryNum string) bool {
+	if eventID == "" {
+		return true
+	}
+	key := datastore.NewKey(ctx, receivedEventKind, eventID, 0, nil)
+
+	claimed := false
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var received ReceivedEvent
+		err := datastore.Get(tc, key, &received)
+		if err == nil {
+			return nil
+		}
+		if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		received = ReceivedEvent{Type: eventType, RetryNum: retryNum, ReceivedAt: time.Now()}
+		if _, err := datastore.Put(tc, key, &received); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "event claim %s: %v", eventID, err)
+		return false
+	}
+	if !claimed {
+		log.Infof(ctx, "event %s: already handled, redelivery %s", eventID, retryNum)
+	}
+
+	return claimed
+}
+
+// welcome sends a greeting DM with the next event to a new member
+// ref: https://api.slack.com/events/team_join
+func welcome(ctx context.Context, userID string) {
+	if inHiatus(ctx, time.Now()) {
+		return
+	}
+
+	event, ok := nextEvent(ctx)
+	series := defaultSeriesConfig
+	if ok {
+		series = seriesConfigFor(ctx, event)
+	}
+
+	text := fmt.Sprintf(textWelcome, series.GeneralChannel, series.Hashtag)
+	if ok {
+		text += fmt.Sprintf(textWelcomeNextEvent, event.Title, formatTimeIn(event.StartedAt, "2006/01/02 15:04", userLocation(ctx, userID)), event.Place, event.URL)
+	}
+
+	if _, err := postMessage(ctx, userID, text); err != nil {
+		log.Errorf(ctx, "welcome %s: %v", userID, err)
+	}
+}
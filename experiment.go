@@ -0,0 +1,141 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	experimentKind = "TemplateExperiment"
+	// the template of settings.yaml templates (or the built-in one) is variant a, the one of variants is b
+	variantA = "a"
+	variantB = "b"
+)
+
+// TemplateExperiment counts the sends of each variant of a rule, keyed by the rule name.
+// Like short URLs it is shared by all communities.
+type TemplateExperiment struct {
+	Rule  string
+	SentA int
+	SentB int
+}
+
+// experimentResult is a variant listed by /admin/experiments
+type experimentResult struct {
+	Rule    string `json:"rule"`
+	Variant string `json:"variant"`
+	Sent    int    `json:"sent"`
+	Clicks  int    `json:"clicks"`
+}
+
+func experimentKey(ctx context.Context, rule string) *datastore.Key {
+	return datastore.NewKey(ctx, experimentKind, rule, 0, nil)
+}
+
+func loadExperiment(ctx context.Context, rule string) TemplateExperiment {
+	var experiment TemplateExperiment
+	if err := datastore.Get(ctx, experimentKey(ctx, rule), &experiment); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "experiment get %s: %v", rule, err)
+	}
+	return experiment
+}
+
+// nextVariant alternates the variants of the rule, "" when it has no experiment
+func nextVariant(ctx context.Context, rule string) string {
+	if _, ok := currentSettings(ctx).Variants[rule]; !ok {
+		return ""
+	}
+
+	experiment := loadExperiment(rootContext(ctx), rule)
+	if experiment.SentB < experiment.SentA {
+		return variantB
+	}
+	return variantA
+}
+
+// countVariant records that the variant of the rule was sent
+func countVariant(ctx context.Context, rule, variant string) {
+	if variant == "" {
+		return
+	}
+
+	ctx = rootContext(ctx)
+	key := experimentKey(ctx, rule)
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var experiment TemplateExperiment
+		if err := datastore.Get(tc, key, &experiment); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		experiment.Rule = rule
+		if variant == variantB {
+			experiment.SentB++
+		} else {
+			experiment.SentA++
+		}
+		_, err := datastore.Put(tc, key, &experiment)
+		return err
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "experiment put %s: %v", rule, err)
+	}
+}
+
+// variantKind is the notification type short URLs are counted by, so clicks are attributed to the variant
+func variantKind(rule, variant string) string {
+	if variant == "" {
+		return rule
+	}
+	return rule + "/" + variant
+}
+
+// handleExperiments lists the sends and short URL clicks of each variant
+func handleExperiments(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleViewer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := appengine.NewContext(r)
+
+	var experiments []TemplateExperiment
+	if _, err := datastore.NewQuery(experimentKind).GetAll(ctx, &experiments); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(experiments, func(i, j int) bool { return experiments[i].Rule < experiments[j].Rule })
+
+	results := []experimentResult{}
+	for _, experiment := range experiments {
+		for _, variant := range []experimentResult{
+			{Rule: experiment.Rule, Variant: variantA, Sent: experiment.SentA},
+			{Rule: experiment.Rule, Variant: variantB, Sent: experiment.SentB},
+		} {
+			var shorts []ShortURL
+			if _, err := datastore.NewQuery(shortURLKind).Filter("Type =", variantKind(variant.Rule, variant.Variant)).GetAll(ctx, &shorts); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, short := range shorts {
+				variant.Clicks += short.Clicks
+			}
+			results = append(results, variant)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// variantRule returns the rule with the template of the variant
+func (s *Settings) variantRule(rule rules.Rule, variant string) rules.Rule {
+	if text, ok := s.Variants[rule.Name]; ok && variant == variantB {
+		rule.Template = text
+	}
+	return rule
+}
@@ -0,0 +1,126 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	attendanceKind      = "AttendanceSnapshot"
+	forecastLowRatio    = 0.8
+	textForecast        = "このペースだと当日はおよそ%d人 (シリーズ平均 %d人)\n"
+	textForecastWarning = "⚠ シリーズ平均を大きく下回りそうです。宣伝を強化しましょう！\n"
+)
+
+// AttendanceSnapshot is the accepted count of an event at some days before it, keyed by event ID and days
+type AttendanceSnapshot struct {
+	EventURL   string
+	DaysBefore int
+	Accepted   int
+	Limit      int
+	RecordedAt time.Time
+}
+
+func attendanceKey(ctx context.Context, eventURL string, daysBefore int) *datastore.Key {
+	return datastore.NewKey(ctx, attendanceKind, fmt.Sprintf("%s %d", eventKeyName(eventURL), daysBefore), 0, nil)
+}
+
+// recordAttendance keeps the latest accepted count of the day, building the signup curve
+func recordAttendance(ctx context.Context, event Event, now time.Time) {
+	daysBefore := rules.DaysUntil(event.StartedAt, now)
+	if daysBefore < 0 {
+		return
+	}
+
+	snapshot := AttendanceSnapshot{
+		EventURL:   event.URL,
+		DaysBefore: daysBefore,
+		Accepted:   event.Accepted,
+		Limit:      event.Limit,
+		RecordedAt: now,
+	}
+	if _, err := datastore.Put(ctx, attendanceKey(ctx, event.URL, daysBefore), &snapshot); err != nil {
+		log.Errorf(ctx, "attendance put %s: %v", event.URL, err)
+	}
+}
+
+// acceptedSince returns how many more participants were accepted than on the day of at, 0 without a snapshot
+func acceptedSince(ctx context.Context, event Event, at time.Time) int {
+	if at.IsZero() {
+		return 0
+	}
+
+	var snapshot AttendanceSnapshot
+	if err := datastore.Get(ctx, attendanceKey(ctx, event.URL, rules.DaysUntil(event.StartedAt, at)), &snapshot); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "attendance get %s: %v", event.URL, err)
+		}
+		return 0
+	}
+
+	return event.Accepted - snapshot.Accepted
+}
+
+// forecastAttendance projects the final accepted count from past events of the series
+// at the same days before, returning the projection and the series average
+func forecastAttendance(ctx context.Context, event Event, now time.Time) (projected, average int, ok bool) {
+	daysBefore := rules.DaysUntil(event.StartedAt, now)
+
+	var past []ArchivedEvent
+	for _, archived := range archivedEvents(ctx, time.Time{}, event.StartedAt) {
+		if archived.SeriesID == event.SeriesID && archived.URL != event.URL && isEnded(archived.EndedAt) {
+			past = append(past, archived)
+		}
+	}
+	if len(past) == 0 {
+		return 0, 0, false
+	}
+
+	keys := make([]*datastore.Key, len(past))
+	for i, archived := range past {
+		keys[i] = attendanceKey(ctx, archived.URL, daysBefore)
+	}
+	snapshots := make([]AttendanceSnapshot, len(past))
+	errs, _ := datastore.GetMulti(ctx, keys, snapshots).(appengine.MultiError)
+
+	var ratio float64
+	var total, samples int
+	for i, archived := range past {
+		total += archived.Accepted
+		if errs != nil && errs[i] != nil {
+			continue
+		}
+		if snapshots[i].Accepted > 0 {
+			ratio += float64(archived.Accepted) / float64(snapshots[i].Accepted)
+			samples++
+		}
+	}
+	if samples == 0 {
+		return 0, 0, false
+	}
+
+	projected = int(math.Round(float64(event.Accepted) * ratio / float64(samples)))
+	average = total / len(past)
+	return projected, average, true
+}
+
+// forecast renders the projection for organizer reports, or "" without enough history
+func forecast(ctx context.Context, event Event) string {
+	projected, average, ok := forecastAttendance(ctx, event, time.Now())
+	if !ok {
+		return ""
+	}
+
+	text := fmt.Sprintf(textForecast, projected, average)
+	if float64(projected) < float64(average)*forecastLowRatio {
+		text += textForecastWarning
+	}
+	return text
+}
@@ -0,0 +1,153 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	memberCountKind     = "MemberCount"
+	notifyMonthlyReport = "monthly_report"
+	textMonthlyReport   = "%d月のレポートです。\nイベント: %d回 / のべ %d人参加\nSlack メンバー: %d人%s\n"
+	textMemberGrowth    = " (前月比 %+d人 / %+.1f%%)"
+)
+
+// MemberCount is the number of Slack members on a day, keyed by date
+type MemberCount struct {
+	Date    time.Time
+	Members int
+}
+
+// usersListResponse ref: https://api.slack.com/methods/users.list
+type usersListResponse struct {
+	Members []struct {
+		Deleted bool `json:"deleted"`
+		IsBot   bool `json:"is_bot"`
+	} `json:"members"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// countMembers counts the active human members of the workspace
+func countMembers(ctx context.Context) (int, error) {
+	count := 0
+	cursor := ""
+	for {
+		params := url.Values{}
+		params.Set("limit", "200")
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+
+		var result usersListResponse
+		if err := callSlackAPIGet(ctx, "users.list", params, &result); err != nil {
+			return 0, err
+		}
+		for _, member := range result.Members {
+			if !member.Deleted && !member.IsBot {
+				count++
+			}
+		}
+
+		if result.ResponseMetadata.NextCursor == "" {
+			return count, nil
+		}
+		cursor = result.ResponseMetadata.NextCursor
+	}
+}
+
+// recordMemberCount stores the member count once a day
+func recordMemberCount(ctx context.Context, now time.Time) {
+	if botToken(ctx) == "" {
+		return
+	}
+
+	key := datastore.NewKey(ctx, memberCountKind, now.Format("2006-01-02"), 0, nil)
+	var count MemberCount
+	if err := datastore.Get(ctx, key, &count); err == nil {
+		return
+	} else if err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "member count get: %v", err)
+		return
+	}
+
+	members, err := countMembers(ctx)
+	if err != nil {
+		log.Errorf(ctx, "member count: %v", err)
+		return
+	}
+
+	count = MemberCount{Date: now, Members: members}
+	if _, err := datastore.Put(ctx, key, &count); err != nil {
+		log.Errorf(ctx, "member count put: %v", err)
+	}
+}
+
+// memberCountSince returns the first member count recorded at or after t
+func memberCountSince(ctx context.Context, t time.Time) (MemberCount, bool) {
+	var counts []MemberCount
+	if _, err := datastore.NewQuery(memberCountKind).Filter("Date >=", t).Order("Date").Limit(1).GetAll(ctx, &counts); err != nil {
+		log.Errorf(ctx, "member count query: %v", err)
+		return MemberCount{}, false
+	}
+	if len(counts) == 0 {
+		return MemberCount{}, false
+	}
+
+	return counts[0], true
+}
+
+// monthlyReport summarizes the events of the month from and the member growth over it
+func monthlyReport(ctx context.Context, from time.Time) (string, bool) {
+	to := from.AddDate(0, 1, 0)
+
+	end, ok := memberCountSince(ctx, to)
+	if !ok {
+		return "", false
+	}
+
+	growth := ""
+	if start, ok := memberCountSince(ctx, from); ok && start.Date.Before(to) && start.Members > 0 {
+		diff := end.Members - start.Members
+		growth = fmt.Sprintf(textMemberGrowth, diff, float64(diff)/float64(start.Members)*100)
+	}
+
+	events := archivedEvents(ctx, from, to)
+	total := 0
+	for _, event := range events {
+		total += event.Accepted
+	}
+
+	return fmt.Sprintf(textMonthlyReport, from.Month(), len(events), total, end.Members, growth), true
+}
+
+// postMonthlyReport posts the report of the last month to #manage on the first day of the month
+func postMonthlyReport(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	recordMemberCount(ctx, now)
+
+	if now.Day() != 1 || !rules.IsRegularTime(now, currentSettings(ctx).RegularHour) {
+		return
+	}
+
+	from := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+	month := from.Format("2006-01")
+	if !loadSent(ctx, notifyMonthlyReport, month).LastSentAt.IsZero() {
+		return
+	}
+
+	text, ok := monthlyReport(ctx, from)
+	if !ok {
+		return
+	}
+
+	notify(ctx, w, notifyMonthlyReport, Event{}, defaultSeriesConfig.ManageChannel, text)
+	markSent(ctx, notifyMonthlyReport, month, now)
+}
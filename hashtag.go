@@ -0,0 +1,209 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+const (
+	xSearchURL      = "https://api.twitter.com/2/tweets/search/recent"
+	xSearchPages    = 5
+	hashtagTopLinks = 2
+	textHashtag     = "#%s の投稿は %d件でした！\n"
+)
+
+var (
+	xBearerToken = os.Getenv("X_BEARER_TOKEN")
+	// hashtagFeedURL is an RSS search feed of a mirror like Nitter, with {hashtag} replaced
+	hashtagFeedURL = os.Getenv("HASHTAG_FEED_URL")
+)
+
+// hashtagPost is a post with the hashtag
+type hashtagPost struct {
+	URL       string
+	Reactions int
+}
+
+// xSearchResponse ref: https://developer.twitter.com/en/docs/twitter-api/tweets/search/api-reference/get-tweets-search-recent
+type xSearchResponse struct {
+	Data []struct {
+		ID            string `json:"id"`
+		AuthorID      string `json:"author_id"`
+		PublicMetrics struct {
+			RetweetCount int `json:"retweet_count"`
+			LikeCount    int `json:"like_count"`
+		} `json:"public_metrics"`
+	} `json:"data"`
+	Includes struct {
+		Users []struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"users"`
+	} `json:"includes"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+}
+
+// searchX returns the posts with the hashtag in [from, to) from the X API
+func searchX(ctx context.Context, hashtag string, from, to time.Time) ([]hashtagPost, error) {
+	var posts []hashtagPost
+	nextToken := ""
+	for page := 0; page < xSearchPages; page++ {
+		params := url.Values{}
+		params.Set("query", "#"+hashtag+" -is:retweet")
+		params.Set("start_time", from.UTC().Format(time.RFC3339))
+		params.Set("end_time", to.UTC().Format(time.RFC3339))
+		params.Set("max_results", "100")
+		params.Set("tweet.fields", "public_metrics,author_id")
+		params.Set("expansions", "author_id")
+		params.Set("user.fields", "username")
+		if nextToken != "" {
+			params.Set("next_token", nextToken)
+		}
+
+		var result xSearchResponse
+		if err := callXAPI(ctx, params, &result); err != nil {
+			return nil, err
+		}
+
+		usernames := map[string]string{}
+		for _, user := range result.Includes.Users {
+			usernames[user.ID] = user.Username
+		}
+		for _, tweet := range result.Data {
+			posts = append(posts, hashtagPost{
+				URL:       fmt.Sprintf("https://twitter.com/%s/status/%s", usernames[tweet.AuthorID], tweet.ID),
+				Reactions: tweet.PublicMetrics.LikeCount + tweet.PublicMetrics.RetweetCount,
+			})
+		}
+
+		if result.Meta.NextToken == "" {
+			break
+		}
+		nextToken = result.Meta.NextToken
+	}
+
+	return posts, nil
+}
+
+// rssFeed ref: https://www.rssboard.org/rss-specification
+type rssFeed struct {
+	Items []struct {
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+// searchFeed returns the posts with the hashtag in [from, to) from the RSS mirror, which has no reaction counts
+func searchFeed(ctx context.Context, hashtag string, from, to time.Time) ([]hashtagPost, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.Replace(hashtagFeedURL, "{hashtag}", url.QueryEscape(hashtag), -1), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed: unexpected status %s", resp.Status)
+	}
+
+	var feed rssFeed
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var posts []hashtagPost
+	for _, item := range feed.Items {
+		published, err := time.Parse(time.RFC1123Z, item.PubDate)
+		if err != nil {
+			published, err = time.Parse(time.RFC1123, item.PubDate)
+		}
+		if err != nil || published.Before(from) || !published.Before(to) {
+			continue
+		}
+		posts = append(posts, hashtagPost{URL: item.Link})
+	}
+
+	return posts, nil
+}
+
+// callXAPI searches recent posts with the bearer token and decodes the response
+func callXAPI(ctx context.Context, params url.Values, result interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, xSearchURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+xBearerToken)
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("x: unexpected status %s", resp.Status)
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// hashtagActivity summarizes the posts with the hashtag on the day of the event:
+// the count and the most reacted links. It is "" when no source is configured or the search fails.
+func hashtagActivity(ctx context.Context, event Event, hashtag string) string {
+	start := event.StartedAt
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	to := from.AddDate(0, 0, 1)
+
+	var posts []hashtagPost
+	var err error
+	switch {
+	case xBearerToken != "":
+		posts, err = searchX(ctx, hashtag, from, to)
+	case hashtagFeedURL != "":
+		posts, err = searchFeed(ctx, hashtag, from, to)
+	default:
+		return ""
+	}
+	if err != nil {
+		log.Errorf(ctx, "hashtag %s: %v", hashtag, err)
+		return ""
+	}
+	if len(posts) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].Reactions > posts[j].Reactions })
+	text := fmt.Sprintf(textHashtag, hashtag, len(posts))
+	for i, post := range posts {
+		if i == hashtagTopLinks {
+			break
+		}
+		text += "• " + post.URL + "\n"
+	}
+
+	return text
+}
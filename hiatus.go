@@ -0,0 +1,136 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	hiatusKind         = "Hiatus"
+	hiatusName         = "current"
+	notifyHiatusEvent  = "hiatus_event"
+	textHiatusSet      = "%s〜%s を休止期間にしました。メンバー向けの通知は送らず、#manage 向けの通知と新しいイベントの検知だけ続けます。"
+	textHiatusCurrent  = "%s〜%s は休止期間です (<@%s> さんが設定)。/nfug hiatus off で解除できます。"
+	textHiatusOff      = "休止期間を解除しました。"
+	textHiatusNone     = "休止期間は設定されていません。"
+	textHiatusInvalid  = "/nfug hiatus 2024-12-25 2025-01-05 のように開始日と終了日を指定してください。"
+	textHiatusNoRole   = "休止期間を設定する権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+	textHiatusNewEvent = "休止期間中のためメンバーには知らせていませんが、新しいイベントの受付が始まりました\n%s"
+)
+
+// Hiatus is a date range, both ends included, when member-facing notifications are dropped
+type Hiatus struct {
+	From      string
+	To        string
+	UserID    string
+	CreatedAt time.Time
+}
+
+func hiatusKey(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, hiatusKind, hiatusName, 0, nil)
+}
+
+func loadHiatus(ctx context.Context) (Hiatus, bool) {
+	var hiatus Hiatus
+	if err := datastore.Get(ctx, hiatusKey(ctx), &hiatus); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "hiatus get: %v", err)
+		}
+		return Hiatus{}, false
+	}
+	return hiatus, true
+}
+
+// inHiatus reports whether t is within the hiatus of the community
+func inHiatus(ctx context.Context, t time.Time) bool {
+	hiatus, ok := loadHiatus(ctx)
+	if !ok {
+		return false
+	}
+	date := t.Format("2006-01-02")
+	return hiatus.From <= date && date <= hiatus.To
+}
+
+// organizerFacing reports whether the notification goes to organizers, which continue during a hiatus.
+// Ad-hoc reminders were asked for explicitly, so they are sent as well.
+func organizerFacing(ctx context.Context, kind, channel string) bool {
+	if kind == notifyAlert || kind == notifyReminder || kind == notifyTaskRepost || kind == notifyTaskLead {
+		return true
+	}
+	if rule, ok := rules.Find(kind); ok {
+		return rule.Channel == rules.Manage
+	}
+
+	s := currentSettings(ctx)
+	if channel == defaultSeriesConfig.ManageChannel || channel == s.AlertChannel || channel == s.Lead {
+		return true
+	}
+	for _, series := range s.Series {
+		if channel == series.ManageChannel {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressHiatus drops the member-facing notification during a hiatus and reports whether it did.
+// New events are still told to #manage so that organizers notice them.
+func suppressHiatus(ctx context.Context, w http.ResponseWriter, kind string, event Event, channel, text string) bool {
+	if organizerFacing(ctx, kind, channel) || !inHiatus(ctx, time.Now()) {
+		return false
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:   auditSuppressed,
+		Type:     kind,
+		Channel:  channel,
+		EventURL: event.URL,
+		Text:     text,
+	})
+	if kind == rules.RegistrationOpened {
+		notify(ctx, w, notifyHiatusEvent, event, seriesConfigFor(ctx, event).ManageChannel, fmt.Sprintf(textHiatusNewEvent, text))
+	}
+	return true
+}
+
+// commandHiatus handles "/nfug hiatus 2024-12-25 2025-01-05", "/nfug hiatus off" and "/nfug hiatus"
+func commandHiatus(ctx context.Context, userID string, args []string) string {
+	if len(args) == 0 {
+		hiatus, ok := loadHiatus(ctx)
+		if !ok {
+			return textHiatusNone
+		}
+		return fmt.Sprintf(textHiatusCurrent, hiatus.From, hiatus.To, hiatus.UserID)
+	}
+	if userRole(userID) < roleOrganizer {
+		return textHiatusNoRole
+	}
+
+	if args[0] == "off" {
+		if err := datastore.Delete(ctx, hiatusKey(ctx)); err != nil && err != datastore.ErrNoSuchEntity {
+			return err.Error()
+		}
+		return textHiatusOff
+	}
+
+	if len(args) != 2 {
+		return textHiatusInvalid
+	}
+	from, err1 := time.ParseInLocation("2006-01-02", args[0], time.Local)
+	to, err2 := time.ParseInLocation("2006-01-02", args[1], time.Local)
+	if err1 != nil || err2 != nil || to.Before(from) {
+		return textHiatusInvalid
+	}
+
+	hiatus := Hiatus{From: args[0], To: args[1], UserID: userID, CreatedAt: time.Now()}
+	if _, err := datastore.Put(ctx, hiatusKey(ctx), &hiatus); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf(textHiatusSet, hiatus.From, hiatus.To)
+}
@@ -0,0 +1,102 @@
+package slackbot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// postMetadataType marks the posts of the bot with the idempotency key of the notification
+// ref: https://api.slack.com/metadata/using
+const postMetadataType = "nfug_notification"
+
+// newIdempotencyKey returns a key identifying one notification across its retries
+func newIdempotencyKey() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// postMetadata is the message metadata carrying the idempotency key
+func postMetadata(kind, key string) map[string]interface{} {
+	return map[string]interface{}{
+		"event_type": postMetadataType,
+		"event_payload": map[string]interface{}{
+			"key":  key,
+			"kind": kind,
+		},
+	}
+}
+
+// ambiguous reports whether a failed post may have been posted anyway,
+// e.g. a timeout after Slack received the request. Errors answered by Slack are not.
+func ambiguous(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch err.(type) {
+	case *slackAPIError, *rateLimitedError:
+		return false
+	}
+	return true
+}
+
+// historyChannel resolves the channel ID conversations.history reads the post from
+func historyChannel(ctx context.Context, channel string) (string, error) {
+	if stagingChannel != "" {
+		channel = stagingChannel
+	}
+	if !isUserID(channel) {
+		return publicChannelID(ctx, channel)
+	}
+
+	// ref: https://api.slack.com/methods/conversations.open
+	opened, err := callSlackAPI(ctx, "conversations.open", map[string]interface{}{"users": channel, "return_im": true})
+	if err != nil {
+		return "", err
+	}
+	if opened.Channel == "" {
+		return "", fmt.Errorf("no DM channel with %s", channel)
+	}
+	return opened.Channel, nil
+}
+
+// conversationsHistoryResponse ref: https://api.slack.com/methods/conversations.history
+type conversationsHistoryResponse struct {
+	Messages []struct {
+		TS       string `json:"ts"`
+		Metadata struct {
+			EventType    string            `json:"event_type"`
+			EventPayload map[string]string `json:"event_payload"`
+		} `json:"metadata"`
+	} `json:"messages"`
+}
+
+// findPosted looks for a message with the idempotency key posted to the channel since the first attempt
+func findPosted(ctx context.Context, channel, key string, since time.Time) (slackAPIResponse, bool, error) {
+	id, err := historyChannel(ctx, channel)
+	if err != nil {
+		return slackAPIResponse{}, false, err
+	}
+
+	params := url.Values{}
+	params.Set("channel", id)
+	params.Set("oldest", strconv.FormatFloat(float64(since.Add(-time.Second).UnixNano())/1e9, 'f', 6, 64))
+	params.Set("include_all_metadata", "true")
+	params.Set("limit", "100")
+
+	var result conversationsHistoryResponse
+	if err := callSlackAPIGet(ctx, "conversations.history", params, &result); err != nil {
+		return slackAPIResponse{}, false, err
+	}
+	for _, message := range result.Messages {
+		if message.Metadata.EventType == postMetadataType && message.Metadata.EventPayload["key"] == key {
+			return slackAPIResponse{OK: true, Channel: id, TS: message.TS}, true, nil
+		}
+	}
+	return slackAPIResponse{}, false, nil
+}
@@ -0,0 +1,87 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	idleKind       = "IdlePeriod"
+	idleName       = "current"
+	notifyNoEvents = "no_events"
+	// nudges are weekly at first, then twice a week once idle for no_events_escalate_days
+	idleNudgeEveryDays     = 7
+	idleEscalatedEveryDays = 3
+	textNoEvents           = "次のイベントが %d 日間決まっていません。そろそろ次回の日程や会場を相談しませんか？"
+	textNoEventsEscalated  = "次のイベントが決まらないまま %d 日が経ちました。/nfug poll で日程調整を始めたり、plan のショートカットで候補日を登録したりしましょう！"
+)
+
+// IdlePeriod is since when connpass has had no upcoming event, removed as soon as one appears
+type IdlePeriod struct {
+	Since    time.Time
+	NudgedAt time.Time
+}
+
+func idleKey(ctx context.Context) *datastore.Key {
+	return datastore.NewKey(ctx, idleKind, idleName, 0, nil)
+}
+
+// nudgeNoEvents reminds #manage, more and more often, while no event is scheduled on connpass
+func nudgeNoEvents(ctx context.Context, w http.ResponseWriter, events []Event, now time.Time) {
+	after := currentSettings(ctx).NoEventsNudgeDays
+	if after <= 0 {
+		return
+	}
+	// nothing is known before the first sync
+	if loadConnpassCache(ctx).Body == nil {
+		return
+	}
+
+	for _, event := range events {
+		if !isEnded(event.EndedAt) {
+			if err := datastore.Delete(ctx, idleKey(ctx)); err != nil && err != datastore.ErrNoSuchEntity {
+				log.Errorf(ctx, "idle delete: %v", err)
+			}
+			return
+		}
+	}
+
+	var idle IdlePeriod
+	if err := datastore.Get(ctx, idleKey(ctx), &idle); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "idle get: %v", err)
+		return
+	}
+	if idle.Since.IsZero() {
+		idle.Since = now
+		if _, err := datastore.Put(ctx, idleKey(ctx), &idle); err != nil {
+			log.Errorf(ctx, "idle put: %v", err)
+		}
+		return
+	}
+
+	// no events are expected during a hiatus
+	if inHiatus(ctx, now) || !rules.IsRegularTime(now, currentSettings(ctx).RegularHour) {
+		return
+	}
+
+	days := rules.DaysUntil(now, idle.Since)
+	text, every := fmt.Sprintf(textNoEvents, days), idleNudgeEveryDays
+	if escalate := currentSettings(ctx).NoEventsEscalateDays; escalate > 0 && days >= escalate {
+		text, every = fmt.Sprintf(textNoEventsEscalated, days), idleEscalatedEveryDays
+	}
+	if days < after || (!idle.NudgedAt.IsZero() && rules.DaysUntil(now, idle.NudgedAt) < every) {
+		return
+	}
+
+	notify(ctx, w, notifyNoEvents, Event{}, defaultSeriesConfig.ManageChannel, text)
+	idle.NudgedAt = now
+	if _, err := datastore.Put(ctx, idleKey(ctx), &idle); err != nil {
+		log.Errorf(ctx, "idle put: %v", err)
+	}
+}
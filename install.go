@@ -0,0 +1,175 @@
+package slackbot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	installationKind = "Installation"
+	oauthStateCookie = "slack_oauth_state"
+	// botScopes are the scopes the features of the bot need
+	botScopes = "chat:write,chat:write.customize,commands,pins:write,reactions:read,im:write,dnd:read,users:read,channels:read,channels:join,channels:history,im:history"
+)
+
+var (
+	slackClientID     = os.Getenv("SLACK_CLIENT_ID")
+	slackClientSecret = os.Getenv("SLACK_CLIENT_SECRET")
+)
+
+// Installation is the bot token of a workspace the bot is installed in, keyed by team ID
+type Installation struct {
+	TeamID      string
+	TeamName    string
+	BotUserID   string
+	BotToken    string `datastore:",noindex"`
+	InstalledAt time.Time
+}
+
+// oauthAccessResponse is the response of oauth.v2.access
+// ref: https://api.slack.com/methods/oauth.v2.access
+type oauthAccessResponse struct {
+	OK          bool   `json:"ok"`
+	Error       string `json:"error"`
+	AccessToken string `json:"access_token"`
+	BotUserID   string `json:"bot_user_id"`
+	Team        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+}
+
+type botTokenKey struct{}
+
+// withTeam makes the Slack calls made with ctx use the bot token of the installed team.
+// Without an installation the SLACK_BOT_TOKEN is used.
+func withTeam(ctx context.Context, teamID string) context.Context {
+	if teamID == "" {
+		return ctx
+	}
+
+	// installations are shared by all communities
+	root := rootContext(ctx)
+	var installation Installation
+	key := datastore.NewKey(root, installationKind, teamID, 0, nil)
+	if err := datastore.Get(root, key, &installation); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "installation get %s: %v", teamID, err)
+		}
+		return ctx
+	}
+
+	return context.WithValue(ctx, botTokenKey{}, installation.BotToken)
+}
+
+// botToken returns the bot token for ctx
+func botToken(ctx context.Context) string {
+	if token, ok := ctx.Value(botTokenKey{}).(string); ok {
+		return token
+	}
+	return slackBotToken
+}
+
+func oauthRedirectURL(ctx context.Context) string {
+	return strings.TrimSuffix(appBaseURL(ctx), "/") + "/slack/oauth_redirect"
+}
+
+// handleInstall redirects to the Slack authorization page
+// ref: https://api.slack.com/authentication/oauth-v2
+func handleInstall(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	if slackClientID == "" {
+		http.Error(w, "SLACK_CLIENT_ID is not set", http.StatusNotFound)
+		return
+	}
+
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(buffer)
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: state, Path: "/slack/", MaxAge: 600, HttpOnly: true, Secure: true})
+
+	query := url.Values{
+		"client_id":    {slackClientID},
+		"scope":        {botScopes},
+		"redirect_uri": {oauthRedirectURL(ctx)},
+		"state":        {state},
+	}
+	http.Redirect(w, r, "https://slack.com/oauth/v2/authorize?"+query.Encode(), http.StatusFound)
+}
+
+// handleOAuthRedirect exchanges the code for a bot token and stores the installation
+func handleOAuthRedirect(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || cookie.Value == "" || cookie.Value != r.URL.Query().Get("state") {
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+	if reason := r.URL.Query().Get("error"); reason != "" {
+		http.Error(w, reason, http.StatusForbidden)
+		return
+	}
+
+	form := url.Values{
+		"code":         {r.URL.Query().Get("code")},
+		"redirect_uri": {oauthRedirectURL(ctx)},
+	}
+	req, err := http.NewRequest(http.MethodPost, slackAPIURL+"oauth.v2.access", strings.NewReader(form.Encode()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(slackClientID, slackClientSecret)
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	var access oauthAccessResponse
+	if err := json.NewDecoder(resp.Body).Decode(&access); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !access.OK {
+		http.Error(w, fmt.Sprintf("slack oauth.v2.access: %s", access.Error), http.StatusBadGateway)
+		return
+	}
+
+	installation := Installation{
+		TeamID:      access.Team.ID,
+		TeamName:    access.Team.Name,
+		BotUserID:   access.BotUserID,
+		BotToken:    access.AccessToken,
+		InstalledAt: time.Now(),
+	}
+	key := datastore.NewKey(ctx, installationKind, installation.TeamID, 0, nil)
+	if _, err := datastore.Put(ctx, key, &installation); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "%s にインストールしました。settings.yaml の series に team: %s を設定すると通知先になります。\n", installation.TeamName, installation.TeamID)
+}
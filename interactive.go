@@ -0,0 +1,112 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+// interactionPayload is the payload of interactive components, shortcuts and modals
+// ref: https://api.slack.com/reference/interaction-payloads
+type interactionPayload struct {
+	Type       string `json:"type"`
+	CallbackID string `json:"callback_id"`
+	TriggerID  string `json:"trigger_id"`
+	User       struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	Container struct {
+		ChannelID string `json:"channel_id"`
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	View struct {
+		CallbackID string `json:"callback_id"`
+		State      struct {
+			Values map[string]map[string]viewStateValue `json:"values"`
+		} `json:"state"`
+	} `json:"view"`
+}
+
+// viewStateValue is the submitted value of an input
+// ref: https://api.slack.com/reference/interaction-payloads/views#view_submission
+type viewStateValue struct {
+	Value        string `json:"value"`
+	SelectedDate string `json:"selected_date"`
+}
+
+// handleInteractive receives button clicks
+func handleInteractive(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	body, err := verifySlackRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload interactionPayload
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx = forTeam(ctx, payload.Team.ID)
+	switch payload.Type {
+	case "shortcut":
+		if payload.CallbackID == planCallbackID {
+			if err := openPlanModal(ctx, payload.TriggerID); err != nil {
+				log.Errorf(ctx, "plan modal: %v", err)
+			}
+		}
+	case "view_submission":
+		// an empty 200 closes the modal
+		if payload.View.CallbackID == planCallbackID {
+			if err := submitPlan(ctx, payload.User.ID, payload.View.State.Values); err != nil {
+				log.Errorf(ctx, "plan submit: %v", err)
+			}
+		}
+	}
+
+	for _, action := range payload.Actions {
+		switch {
+		case strings.HasPrefix(action.ActionID, pollVoteAction):
+			if err := votePoll(ctx, payload.User.ID, action.Value); err != nil {
+				log.Errorf(ctx, "poll vote: %v", err)
+			}
+		case action.ActionID == approveAction || action.ActionID == rejectAction:
+			if err := decideApproval(ctx, payload.User.ID, payload.Container.ChannelID, action.Value, action.ActionID == approveAction); err != nil {
+				log.Errorf(ctx, "approval: %v", err)
+			}
+		case action.ActionID == claimOrganizerAction:
+			organizer, err := claimOrganizer(ctx, payload.User.ID, action.Value)
+			if err != nil {
+				log.Errorf(ctx, "organizer claim: %v", err)
+				continue
+			}
+			if err := updateMessage(ctx, payload.Container.ChannelID, payload.Container.MessageTS, payload.Message.Text, claimedBlocks(payload.Message.Text, organizer)...); err != nil {
+				log.Errorf(ctx, "organizer update: %v", err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
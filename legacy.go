@@ -0,0 +1,122 @@
+package slackbot
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+// urlKeyedKinds are keyed by event ID, and were keyed by the URL as connpass returned it, then by the canonical URL
+var urlKeyedKinds = []string{snapshotKind, organizerKind, lifecycleKind, announcementKind, summaryKind, streamKind, participantsKind, tagsKind, ticketsKind}
+
+// migratedEvents are the events whose records this instance has already moved, so that cron runs
+// don't look for legacy records of every event again
+var migratedEvents sync.Map
+
+// eventKey is the key of the record of the event in the kind
+func eventKey(ctx context.Context, kind, eventURL string) *datastore.Key {
+	return datastore.NewKey(ctx, kind, eventKeyName(eventURL), 0, nil)
+}
+
+// urlFieldKinds find their event by the EventURL field, with keys that don't contain the URL
+var urlFieldKinds = []string{talkKind, linkKind, photoThreadKind, taskKind}
+
+// setEventURL rewrites the EventURL field of the entity, if it has one
+func setEventURL(entity interface{}, eventURL string) {
+	if field := reflect.ValueOf(entity).Elem().FieldByName("EventURL"); field.IsValid() && field.Kind() == reflect.String {
+		field.SetString(eventURL)
+	}
+}
+
+// migrateEventKeys moves the records of the event written under the URL as connpass returned it, or under
+// the canonical URL, to the event ID, so that they are still found after the keys changed.
+// Records already written under the event ID win, and the legacy ones are deleted either way.
+// The EventURL fields of records found by their event are rewritten to the canonical URL.
+func migrateEventKeys(ctx context.Context, event Event) {
+	// the namespace of the community, as events of communities may share URLs
+	namespace := datastore.NewKey(ctx, sentKind, "", 1, nil).Namespace()
+	if _, done := migratedEvents.LoadOrStore(namespace+" "+event.URL, true); done {
+		return
+	}
+
+	var legacyURLs []string
+	for _, u := range []string{event.RawURL, event.URL} {
+		if u != "" && u != eventKeyName(event.URL) && (len(legacyURLs) == 0 || legacyURLs[0] != u) {
+			legacyURLs = append(legacyURLs, u)
+		}
+	}
+
+	move := func(kind string, legacy, key *datastore.Key, entity interface{}) {
+		if legacy.Equal(key) {
+			return
+		}
+		if err := datastore.Get(ctx, key, stateKinds[kind]()); err == datastore.ErrNoSuchEntity {
+			setEventURL(entity, event.URL)
+			if _, err := datastore.Put(ctx, key, entity); err != nil {
+				log.Errorf(ctx, "migrate %s %s: %v", kind, legacy.StringID(), err)
+				return
+			}
+		}
+		if err := datastore.Delete(ctx, legacy); err != nil {
+			log.Errorf(ctx, "migrate %s %s: %v", kind, legacy.StringID(), err)
+		}
+	}
+
+	for _, legacyURL := range legacyURLs {
+		for _, kind := range urlKeyedKinds {
+			legacy := datastore.NewKey(ctx, kind, legacyURL, 0, nil)
+			entity := stateKinds[kind]()
+			if err := datastore.Get(ctx, legacy, entity); err != nil {
+				if err != datastore.ErrNoSuchEntity {
+					log.Errorf(ctx, "migrate %s %s: %v", kind, legacyURL, err)
+				}
+				continue
+			}
+			move(kind, legacy, eventKey(ctx, kind, event.URL), entity)
+		}
+
+		var sent []SentNotification
+		keys, err := datastore.NewQuery(sentKind).Filter("EventURL =", legacyURL).GetAll(ctx, &sent)
+		if err != nil {
+			log.Errorf(ctx, "migrate %s %s: %v", sentKind, legacyURL, err)
+		}
+		for i := range sent {
+			move(sentKind, keys[i], sentKey(ctx, sent[i].Rule, event.URL), &sent[i])
+		}
+
+		var attendance []AttendanceSnapshot
+		keys, err = datastore.NewQuery(attendanceKind).Filter("EventURL =", legacyURL).GetAll(ctx, &attendance)
+		if err != nil {
+			log.Errorf(ctx, "migrate %s %s: %v", attendanceKind, legacyURL, err)
+		}
+		for i := range attendance {
+			move(attendanceKind, keys[i], attendanceKey(ctx, event.URL, attendance[i].DaysBefore), &attendance[i])
+		}
+	}
+
+	raw := event.RawURL
+	if raw == "" || raw == event.URL {
+		return
+	}
+	for _, kind := range urlFieldKinds {
+		t := datastore.NewQuery(kind).Filter("EventURL =", raw).Run(ctx)
+		for {
+			entity := stateKinds[kind]()
+			key, err := t.Next(entity)
+			if err == datastore.Done {
+				break
+			}
+			if err != nil {
+				log.Errorf(ctx, "migrate %s %s: %v", kind, raw, err)
+				break
+			}
+			setEventURL(entity, event.URL)
+			if _, err := datastore.Put(ctx, key, entity); err != nil {
+				log.Errorf(ctx, "migrate %s %s: %v", kind, raw, err)
+			}
+		}
+	}
+}
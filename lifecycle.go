@@ -0,0 +1,51 @@
+package slackbot
+
+import (
+	"context"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const lifecycleKind = "EventLifecycle"
+
+// EventLifecycle is the lifecycle stage of an event, keyed by event ID
+type EventLifecycle struct {
+	Stage     string
+	EnteredAt time.Time
+}
+
+func loadLifecycle(ctx context.Context, eventURL string) EventLifecycle {
+	key := eventKey(ctx, lifecycleKind, eventURL)
+
+	var lifecycle EventLifecycle
+	if err := datastore.Get(ctx, key, &lifecycle); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "lifecycle get %s: %v", eventURL, err)
+	}
+
+	return lifecycle
+}
+
+// advanceLifecycle moves the event to the stage it has reached, records the transition and returns the stage
+func advanceLifecycle(ctx context.Context, event Event, e rules.Event, now time.Time) rules.Stage {
+	lifecycle := loadLifecycle(ctx, event.URL)
+	current := rules.Stage(lifecycle.Stage)
+	next := rules.Advance(current, e, now)
+	if next == current {
+		return current
+	}
+
+	key := eventKey(ctx, lifecycleKind, event.URL)
+	if _, err := datastore.Put(ctx, key, &EventLifecycle{Stage: string(next), EnteredAt: now}); err != nil {
+		log.Errorf(ctx, "lifecycle put %s: %v", event.URL, err)
+	}
+	recordAudit(ctx, AuditEntry{
+		Action:   auditTransitioned,
+		EventURL: event.URL,
+		Detail:   string(current) + " -> " + string(next),
+	})
+
+	return next
+}
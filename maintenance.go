@@ -0,0 +1,47 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+// maintenances longer than this are alerted as an outage
+const maintenanceGrace = 6 * time.Hour
+
+// isMaintenance reports whether connpass answered with its maintenance page,
+// a 503 or an HTML page instead of JSON
+func isMaintenance(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/html"
+}
+
+// maintenanceEvents returns the cached events during a connpass maintenance.
+// It is logged at info level and alerted only when the window lasts longer than maintenanceGrace.
+func maintenanceEvents(ctx context.Context, w http.ResponseWriter, cache ConnpassCache, resp *http.Response, now time.Time) []Event {
+	if cache.MaintenanceSince.IsZero() {
+		cache.MaintenanceSince = now
+		saveConnpassCache(ctx, cache)
+	}
+	log.Infof(ctx, "connpass: maintenance (%s) since %s", resp.Status, cache.MaintenanceSince.Format(time.RFC3339))
+
+	if lasting := now.Sub(cache.MaintenanceSince); lasting > maintenanceGrace {
+		alert(ctx, w, fmt.Sprintf("connpass がメンテナンス中のまま %s 経過しています (%s)", lasting.Truncate(time.Hour), resp.Status), now)
+	}
+
+	if cache.Body == nil {
+		return nil
+	}
+	events, err := parseEvents(cache.Body)
+	if err != nil {
+		log.Errorf(ctx, "connpass cache: %v", err)
+	}
+	return events
+}
@@ -0,0 +1,89 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const textInviteBot = "%s に投稿できませんでした。プライベートチャンネルの場合は、チャンネルで `/invite @bot の名前` を実行して bot を招待してください (チャンネル名の間違いも確認してください)"
+
+// slackAPIError is an error answered by the Web API
+// ref: https://api.slack.com/web#evaluating_responses
+type slackAPIError struct {
+	Method string
+	Code   string
+}
+
+func (e *slackAPIError) Error() string {
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+// needsMembership reports whether a post failed because the bot is not a member of the channel.
+// Private channels the bot isn't in look like they don't exist.
+func needsMembership(err error) bool {
+	apiErr, ok := err.(*slackAPIError)
+	return ok && (apiErr.Code == "not_in_channel" || apiErr.Code == "channel_not_found")
+}
+
+// conversationsListResponse ref: https://api.slack.com/methods/conversations.list
+type conversationsListResponse struct {
+	Channels []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channels"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// publicChannelID resolves "#name" to the ID of the public channel, which conversations.join needs
+func publicChannelID(ctx context.Context, channel string) (string, error) {
+	if !strings.HasPrefix(channel, "#") {
+		return channel, nil
+	}
+	name := strings.TrimPrefix(channel, "#")
+
+	cursor := ""
+	for {
+		params := url.Values{}
+		params.Set("types", "public_channel")
+		params.Set("exclude_archived", "true")
+		params.Set("limit", "1000")
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+
+		var result conversationsListResponse
+		if err := callSlackAPIGet(ctx, "conversations.list", params, &result); err != nil {
+			return "", err
+		}
+		for _, c := range result.Channels {
+			if c.Name == name {
+				return c.ID, nil
+			}
+		}
+
+		if result.ResponseMetadata.NextCursor == "" {
+			return "", fmt.Errorf("public channel %s not found", channel)
+		}
+		cursor = result.ResponseMetadata.NextCursor
+	}
+}
+
+// joinChannel makes the bot a member of the public channel. Private channels need an invitation.
+// ref: https://api.slack.com/methods/conversations.join
+func joinChannel(ctx context.Context, channel string) error {
+	if stagingChannel != "" {
+		channel = stagingChannel
+	}
+
+	id, err := publicChannelID(ctx, channel)
+	if err != nil {
+		return err
+	}
+
+	_, err = callSlackAPI(ctx, "conversations.join", map[string]interface{}{"channel": id})
+	return err
+}
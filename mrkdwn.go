@@ -0,0 +1,83 @@
+package slackbot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// blocksText degrades Block Kit blocks into mrkdwn text for incoming webhooks without a bot token,
+// keeping links of images and URL buttons. Interactive buttons are dropped as nothing can receive them.
+// ref: https://api.slack.com/reference/block-kit/blocks
+func blocksText(text string, blocks []interface{}) string {
+	var lines []string
+	for _, b := range blocks {
+		block, _ := b.(map[string]interface{})
+		switch block["type"] {
+		case "header":
+			lines = append(lines, "*"+textOf(block["text"])+"*")
+		case "section":
+			lines = append(lines, strings.TrimRight(textOf(block["text"]), "\n"))
+			if accessory, ok := block["accessory"].(map[string]interface{}); ok && accessory["type"] == "image" {
+				lines = append(lines, link(accessory["image_url"], accessory["alt_text"]))
+			}
+		case "image":
+			lines = append(lines, link(block["image_url"], block["alt_text"]))
+		case "context":
+			var texts []string
+			for _, element := range elementsOf(block) {
+				if t := textOf(element); t != "" {
+					texts = append(texts, t)
+				}
+			}
+			lines = append(lines, strings.Join(texts, " "))
+		case "actions":
+			var links []string
+			for _, element := range elementsOf(block) {
+				if url, ok := element["url"].(string); ok && url != "" {
+					links = append(links, link(url, textOf(element["text"])))
+				}
+			}
+			if len(links) > 0 {
+				lines = append(lines, strings.Join(links, " / "))
+			}
+		case "divider":
+			lines = append(lines, "───")
+		}
+	}
+
+	if len(lines) == 0 {
+		return text
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// textOf returns the text of a text object, or of an element holding one
+func textOf(v interface{}) string {
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if text, ok := object["text"].(string); ok {
+		return text
+	}
+	return textOf(object["text"])
+}
+
+func elementsOf(block map[string]interface{}) []map[string]interface{} {
+	items, _ := block["elements"].([]interface{})
+	var elements []map[string]interface{}
+	for _, item := range items {
+		if element, ok := item.(map[string]interface{}); ok {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// link formats a mrkdwn link, the URL alone without a label
+func link(url, label interface{}) string {
+	if l, _ := label.(string); l != "" {
+		return fmt.Sprintf("<%v|%s>", url, l)
+	}
+	return fmt.Sprintf("<%v>", url)
+}
@@ -0,0 +1,78 @@
+package slackbot
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+const textNextDescription = "%s 開催 / 会場: %s / 参加者 %d/%d人"
+
+// nextPage is what /next renders
+type nextPage struct {
+	Title       string
+	Description string
+	URL         string
+	ImageURL    string
+	PageURL     string
+}
+
+// nextTemplate carries the Open Graph tags for social media previews, then sends browsers on to connpass
+// ref: https://ogp.me/
+// ref: https://developer.twitter.com/en/docs/twitter-for-websites/cards/overview/summary-card-with-large-image
+var nextTemplate = template.Must(template.New("next").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.PageURL}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body>
+<p><a href="{{.URL}}">{{.Title}}</a></p>
+<p>{{.Description}}</p>
+</body>
+</html>
+`))
+
+// handleNext serves /next?community={name}, a stable link whose preview always shows the next event.
+// The image is the one of the connpass page, or the generated QR code of the event when it has none.
+func handleNext(w http.ResponseWriter, r *http.Request) {
+	ctx, err := withCommunity(appengine.NewContext(r), r.URL.Query().Get("community"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	event, ok := nextEvent(ctx)
+	if !ok {
+		http.Error(w, "次のイベントはまだありません", http.StatusNotFound)
+		return
+	}
+
+	page := nextPage{
+		Title:       event.Title,
+		Description: fmt.Sprintf(textNextDescription, event.StartedAt.Format("2006/01/02 15:04"), event.Place, event.Accepted, event.Limit),
+		URL:         event.URL,
+		ImageURL:    getEventImageURL(ctx, event.URL),
+		PageURL:     appBaseURL(ctx) + r.URL.RequestURI(),
+	}
+	if page.ImageURL == "" {
+		page.ImageURL = qrCodeURL(ctx, event)
+	}
+
+	// previews are cached by the services anyway, a short max-age keeps the page itself fresh
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := nextTemplate.Execute(w, page); err != nil {
+		log.Errorf(ctx, "next: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/log"
+)
+
+var (
+	outgoingWebhookURLs = splitList(os.Getenv("OUTGOING_WEBHOOK_URLS"))
+)
+
+// WebhookPayload is the stable JSON sent to outgoing webhooks
+type WebhookPayload struct {
+	Type    string       `json:"type"`
+	Channel string       `json:"channel"`
+	Text    string       `json:"text"`
+	Event   WebhookEvent `json:"event"`
+}
+
+// WebhookEvent is the event part of WebhookPayload
+type WebhookEvent struct {
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Place     string    `json:"place"`
+	Limit     int       `json:"limit"`
+	Accepted  int       `json:"accepted"`
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// sendAttempts is how many times a post is tried before it goes to the dead letters
+const sendAttempts = 3
+
+// sendSlack posts via the Web API when a bot token is configured, otherwise via the incoming webhook.
+// Only the Web API returns the posted message (channel ID and ts) and uses the bot identity of the rule.
+// Failed posts are retried with a linear backoff. After a failure that may have been posted anyway,
+// the channel history is searched for the idempotency key first, so a retry doesn't duplicate the message.
+func sendSlack(ctx context.Context, kind, channel, text string, blocks ...interface{}) (slackAPIResponse, error) {
+	key := newIdempotencyKey()
+	since := time.Now()
+
+	var posted slackAPIResponse
+	var err error
+	for attempt := 0; attempt < sendAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, time.Duration(attempt)*time.Second); err != nil {
+				return posted, err
+			}
+		}
+		if ambiguous(err) && botToken(ctx) != "" {
+			found, ok, findErr := findPosted(ctx, channel, key, since)
+			if ok {
+				log.Infof(ctx, "send %s to %s: already posted at %s", kind, channel, found.TS)
+				return found, nil
+			}
+			if findErr != nil {
+				log.Warningf(ctx, "history %s: %v", channel, findErr)
+			}
+		}
+
+		if err = paceChannel(ctx, channel); err != nil {
+			return posted, err
+		}
+		if posted, err = postSlack(ctx, kind, channel, text, key, blocks...); err == nil {
+			return posted, nil
+		}
+		// throttled calls have already waited as told by Slack
+		if _, throttled := err.(*rateLimitedError); throttled {
+			return posted, err
+		}
+		// retrying doesn't make the bot a member
+		if needsMembership(err) {
+			return posted, err
+		}
+		log.Warningf(ctx, "send %s to %s (attempt %d): %v", kind, channel, attempt+1, err)
+	}
+	return posted, err
+}
+
+func postSlack(ctx context.Context, kind, channel, text, key string, blocks ...interface{}) (slackAPIResponse, error) {
+	// the same templates work with the incoming webhook, without the blocks
+	if botToken(ctx) == "" {
+		return slackAPIResponse{}, slackbot(ctx, slackbotURL, channel, blocksText(text, blocks))
+	}
+
+	params := map[string]interface{}{
+		"channel":  channel,
+		"text":     text,
+		"metadata": postMetadata(kind, key),
+	}
+	if len(blocks) > 0 {
+		params["blocks"] = blocks
+	}
+	// ref: https://api.slack.com/methods/chat.postMessage#authorship
+	if rule, ok := rules.Find(kind); ok {
+		if rule.Username != "" {
+			params["username"] = rule.Username
+		}
+		if rule.IconEmoji != "" {
+			params["icon_emoji"] = rule.IconEmoji
+		}
+	}
+
+	// callSlackAPI rewrites params in staging, so a retry needs the original
+	retry := map[string]interface{}{}
+	for k, v := range params {
+		retry[k] = v
+	}
+
+	posted, err := callSlackAPI(ctx, "chat.postMessage", params)
+	// the bot can join public channels by itself
+	if needsMembership(err) && !isUserID(channel) {
+		joinErr := joinChannel(ctx, channel)
+		if joinErr == nil {
+			return callSlackAPI(ctx, "chat.postMessage", retry)
+		}
+		log.Warningf(ctx, "join %s: %v", channel, joinErr)
+	}
+	return posted, err
+}
+
+// notify posts the notification to Slack and fans it out to outgoing webhooks
+// non-critical notifications are deferred during quiet hours and blackout periods,
+// and member-facing ones are dropped during a hiatus
+func notify(ctx context.Context, w http.ResponseWriter, kind string, event Event, channel, text string, blocks ...interface{}) {
+	if suppressHiatus(ctx, w, kind, event, channel, text) {
+		return
+	}
+	if !isCritical(kind) && isQuietTime(ctx, time.Now()) {
+		deferNotification(ctx, kind, event, channel, text, blocks)
+		return
+	}
+
+	// DMs wait until the do-not-disturb window of the user ends
+	if botToken(ctx) != "" && isUserID(channel) {
+		if until, dnd := dndUntil(ctx, channel, time.Now()); dnd {
+			err := scheduleMessage(ctx, channel, text, until, blocks...)
+			if err == nil {
+				recordAudit(ctx, AuditEntry{
+					Action:   auditScheduled,
+					Type:     kind,
+					Channel:  channel,
+					EventURL: event.URL,
+					Text:     text,
+					Detail:   until.Format(time.RFC3339),
+				})
+				return
+			}
+			log.Warningf(ctx, "schedule %s to %s: %v", kind, channel, err)
+		}
+	}
+
+	// cron runs may combine their notifications into one message per channel
+	if report, ok := w.(*runReport); ok && currentSettings(ctx).BatchPerChannel {
+		report.queue(pendingNotification{Kind: kind, Event: event, Channel: channel, Text: text, Blocks: blocks})
+		return
+	}
+
+	// cron runs send in parallel within the send budget
+	if report, ok := w.(*runReport); ok && report.pool != nil {
+		report.pool.submit(ctx, pendingNotification{Kind: kind, Event: event, Channel: channel, Text: text, Blocks: blocks})
+		return
+	}
+	send(ctx, w, kind, event, channel, text, blocks...)
+}
+
+// send posts the notification and sends it to the dead letters when that fails.
+// It returns errSendBudget when the notification was deferred as the send budget ran out.
+func send(ctx context.Context, w http.ResponseWriter, kind string, event Event, channel, text string, blocks ...interface{}) error {
+	posted, err := sendSlack(ctx, kind, channel, text, blocks...)
+	// still throttled or out of time: queue it for the next cron run
+	if _, throttled := err.(*rateLimitedError); throttled || err == errSendBudget {
+		deferNotification(ctx, kind, event, channel, text, blocks)
+		return err
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		deadLetter(ctx, kind, event, channel, text, blocks, err)
+		// an alert that can't be delivered would only alert again
+		if needsMembership(err) && kind != notifyAlert {
+			alert(ctx, w, fmt.Sprintf(textInviteBot, channel), time.Now())
+		}
+		return err
+	}
+	delivered(ctx, kind, event, channel, text, posted)
+	return nil
+}
+
+// delivered runs what follows a posted notification: webhooks, pins and the audit log.
+// The streaming URL is for the participants only, so it isn't sent to the webhooks.
+func delivered(ctx context.Context, kind string, event Event, channel, text string, posted slackAPIResponse) {
+	if kind != notifyStream {
+		fanOutWebhooks(ctx, kind, event, channel, text)
+	}
+
+	switch kind {
+	case rules.TwoWeeksBefore:
+		pinAnnouncement(ctx, event, posted)
+	case rules.NextDay:
+		unpinAnnouncement(ctx, event)
+	case rules.PhotoThread:
+		openPhotoThread(ctx, event, posted)
+	case notifyTaskRepost:
+		trackRepost(ctx, event, posted.Channel, text, posted)
+	}
+	trackTask(ctx, kind, event, channel, text, posted)
+
+	recordAudit(ctx, AuditEntry{
+		Action:    auditSent,
+		Type:      kind,
+		Channel:   channel,
+		ChannelID: posted.Channel,
+		TS:        posted.TS,
+		EventURL:  event.URL,
+		Text:      text,
+	})
+}
+
+func fanOutWebhooks(ctx context.Context, kind string, event Event, channel, text string) {
+	if len(outgoingWebhookURLs) == 0 || stagingChannel != "" {
+		return
+	}
+
+	buffer, _ := json.Marshal(WebhookPayload{
+		Type:    kind,
+		Channel: channel,
+		Text:    text,
+		Event: WebhookEvent{
+			Title:     event.Title,
+			URL:       event.URL,
+			StartedAt: event.StartedAt,
+			EndedAt:   event.EndedAt,
+			Place:     event.Place,
+			Limit:     event.Limit,
+			Accepted:  event.Accepted,
+		},
+	})
+
+	for _, url := range outgoingWebhookURLs {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(buffer))
+		if err != nil {
+			log.Errorf(ctx, "webhook %s: %v", url, err)
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		client, cancel := outboundClient(ctx, outboundTimeout)
+		resp, err := client.Do(req)
+		if err != nil {
+			log.Errorf(ctx, "webhook %s: %v", url, err)
+			cancel()
+			continue
+		}
+		resp.Body.Close()
+		cancel()
+	}
+}
@@ -0,0 +1,126 @@
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+const (
+	notionAPIURL  = "https://api.notion.com/v1/"
+	notionVersion = "2022-06-28"
+)
+
+var (
+	notionToken      = os.Getenv("NOTION_TOKEN")
+	notionDatabaseID = os.Getenv("NOTION_DATABASE_ID")
+)
+
+// callNotionAPI sends a request to the Notion API and decodes the response into result
+// ref: https://developers.notion.com/reference/intro
+func callNotionAPI(ctx context.Context, method, path string, params, result interface{}) error {
+	buffer, _ := json.Marshal(params)
+	req, err := http.NewRequest(method, notionAPIURL+path, bytes.NewBuffer(buffer))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+notionToken)
+	req.Header.Set("Notion-Version", notionVersion)
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notion %s %s: %s", method, path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func notionEventProperties(event Event) map[string]interface{} {
+	status := "開催予定"
+	if isEnded(event.EndedAt) {
+		status = "終了"
+	}
+
+	return map[string]interface{}{
+		"Name": map[string]interface{}{
+			"title": []interface{}{
+				map[string]interface{}{"text": map[string]interface{}{"content": event.Title}},
+			},
+		},
+		"Date": map[string]interface{}{
+			"date": map[string]interface{}{
+				"start": event.StartedAt.Format(time.RFC3339),
+				"end":   event.EndedAt.Format(time.RFC3339),
+			},
+		},
+		"Venue": map[string]interface{}{
+			"rich_text": []interface{}{
+				map[string]interface{}{"text": map[string]interface{}{"content": event.Place}},
+			},
+		},
+		"Status": map[string]interface{}{
+			"select": map[string]interface{}{"name": status},
+		},
+		"Accepted": map[string]interface{}{
+			"number": event.Accepted,
+		},
+		"URL": map[string]interface{}{
+			"url": event.URL,
+		},
+	}
+}
+
+// syncNotion upserts a page for the event in the configured Notion database
+func syncNotion(ctx context.Context, event Event) {
+	if notionToken == "" || notionDatabaseID == "" {
+		return
+	}
+
+	// ref: https://developers.notion.com/reference/post-database-query
+	var query struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	err := callNotionAPI(ctx, http.MethodPost, "databases/"+notionDatabaseID+"/query", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"property": "URL",
+			"url":      map[string]interface{}{"equals": event.URL},
+		},
+	}, &query)
+	if err != nil {
+		log.Errorf(ctx, "notion query %s: %v", event.URL, err)
+		return
+	}
+
+	var page struct{}
+	properties := notionEventProperties(event)
+	if len(query.Results) == 0 {
+		err = callNotionAPI(ctx, http.MethodPost, "pages", map[string]interface{}{
+			"parent":     map[string]interface{}{"database_id": notionDatabaseID},
+			"properties": properties,
+		}, &page)
+	} else {
+		err = callNotionAPI(ctx, http.MethodPatch, "pages/"+query.Results[0].ID, map[string]interface{}{
+			"properties": properties,
+		}, &page)
+	}
+	if err != nil {
+		log.Errorf(ctx, "notion upsert %s: %v", event.URL, err)
+	}
+}
@@ -0,0 +1,95 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	organizerKind        = "Organizer"
+	claimOrganizerAction = "claim_organizer"
+	textClaimOrganizer   = "担当する"
+	textOrganizerClaimed = "担当: <@%s> (以降の運営からのお知らせは DM でお送りします)"
+)
+
+// Organizer is the person in charge of an event, keyed by event ID
+type Organizer struct {
+	EventURL string
+	UserID   string
+}
+
+func loadOrganizer(ctx context.Context, eventURL string) (Organizer, bool) {
+	key := eventKey(ctx, organizerKind, eventURL)
+
+	var organizer Organizer
+	if err := datastore.Get(ctx, key, &organizer); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "organizer get %s: %v", eventURL, err)
+		}
+		return Organizer{}, false
+	}
+
+	return organizer, true
+}
+
+// claimOrganizer assigns the user to the event unless someone else claimed it first.
+// It returns the assigned organizer.
+func claimOrganizer(ctx context.Context, userID, eventURL string) (Organizer, error) {
+	key := eventKey(ctx, organizerKind, eventURL)
+
+	var organizer Organizer
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		err := datastore.Get(tc, key, &organizer)
+		if err == nil {
+			return nil
+		}
+		if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		organizer = Organizer{EventURL: eventURL, UserID: userID}
+		_, err = datastore.Put(tc, key, &organizer)
+		return err
+	}, nil)
+
+	return organizer, err
+}
+
+// claimBlocks appends a button to claim the event to the message
+// ref: https://api.slack.com/reference/block-kit/blocks#actions
+func claimBlocks(text string, event Event, blocks []interface{}) []interface{} {
+	if len(blocks) == 0 {
+		blocks = announcementBlocks(text, "", event.Title)
+	}
+
+	return append(blocks, map[string]interface{}{
+		"type": "actions",
+		"elements": []interface{}{
+			map[string]interface{}{
+				"type":      "button",
+				"text":      map[string]interface{}{"type": "plain_text", "text": textClaimOrganizer},
+				"action_id": claimOrganizerAction,
+				"value":     event.URL,
+			},
+		},
+	})
+}
+
+// claimedBlocks replaces the claim button with the assigned organizer
+func claimedBlocks(text string, organizer Organizer) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+		},
+		map[string]interface{}{
+			"type": "context",
+			"elements": []interface{}{
+				map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf(textOrganizerClaimed, organizer.UserID)},
+			},
+		},
+	}
+}
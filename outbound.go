@@ -0,0 +1,58 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/appengine/urlfetch"
+)
+
+// per-call timeouts of outbound requests
+var (
+	connpassTimeout = 10 * time.Second
+	outboundTimeout = 10 * time.Second
+)
+
+// Doer sends HTTP requests. *http.Client satisfies it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newDoer returns the Doer for outbound requests bound to ctx.
+// Replace it to stub responses, or to configure the transport (proxies, keep-alives) outside App Engine.
+// ref: https://cloud.google.com/appengine/docs/standard/go/issue-requests
+var newDoer = func(ctx context.Context) Doer {
+	return urlfetch.Client(ctx)
+}
+
+// outboundClient returns a Doer bound to ctx with the per-call timeout.
+// cancel must be called once the response body has been consumed.
+func outboundClient(ctx context.Context, timeout time.Duration) (Doer, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return newDoer(ctx), cancel
+}
+
+func loadTimeoutConfig() []string {
+	var errs []string
+
+	for name, timeout := range map[string]*time.Duration{
+		"CONNPASS_TIMEOUT": &connpassTimeout,
+		"OUTBOUND_TIMEOUT": &outboundTimeout,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			errs = append(errs, fmt.Sprintf("%s: invalid duration %q", name, raw))
+			continue
+		}
+		*timeout = d
+	}
+
+	return errs
+}
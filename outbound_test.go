@@ -0,0 +1,114 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubDoer answers requests with canned responses and records them
+type stubDoer struct {
+	status   int
+	body     string
+	requests []*http.Request
+}
+
+func (d *stubDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+	return &http.Response{
+		StatusCode: d.status,
+		Status:     http.StatusText(d.status),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(d.body)),
+	}, nil
+}
+
+// stubOutbound swaps newDoer for the test
+func stubOutbound(t *testing.T, doer *stubDoer) {
+	original := newDoer
+	newDoer = func(ctx context.Context) Doer { return doer }
+	t.Cleanup(func() { newDoer = original })
+}
+
+func TestFetchParticipants(t *testing.T) {
+	doer := &stubDoer{status: http.StatusOK, body: `
+<a href="https://connpass.com/user/alice/">alice</a>
+<a href="https://connpass.com/user/bob/">bob</a>
+<a href="https://connpass.com/user/alice/">alice</a>`}
+	stubOutbound(t, doer)
+
+	nicknames, err := fetchParticipants(context.Background(), Event{URL: "https://nfug.connpass.com/event/123/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(nicknames, want) {
+		t.Errorf("nicknames = %v, want %v", nicknames, want)
+	}
+	if got := doer.requests[0].URL.String(); got != "https://nfug.connpass.com/event/123/participation/" {
+		t.Errorf("requested %s", got)
+	}
+}
+
+func TestFetchParticipantsStatus(t *testing.T) {
+	stubOutbound(t, &stubDoer{status: http.StatusNotFound})
+
+	if _, err := fetchParticipants(context.Background(), Event{URL: "https://nfug.connpass.com/event/123/"}); err == nil {
+		t.Error("want an error for 404")
+	}
+}
+
+func TestCallSlackAPI(t *testing.T) {
+	doer := &stubDoer{status: http.StatusOK, body: `{"ok":true,"channel":"C123","ts":"1700000000.000100"}`}
+	stubOutbound(t, doer)
+	ctx := context.WithValue(context.Background(), botTokenKey{}, "xoxb-test")
+
+	posted, err := callSlackAPI(ctx, "chat.postMessage", map[string]interface{}{"channel": "#general", "text": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted.Channel != "C123" || posted.TS != "1700000000.000100" {
+		t.Errorf("posted = %+v", posted)
+	}
+
+	req := doer.requests[0]
+	if got := req.Header.Get("Authorization"); got != "Bearer xoxb-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	var params map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		t.Fatal(err)
+	}
+	if params["channel"] != "#general" || params["text"] != "hello" {
+		t.Errorf("params = %v", params)
+	}
+}
+
+func TestCallSlackAPIError(t *testing.T) {
+	stubOutbound(t, &stubDoer{status: http.StatusOK, body: `{"ok":false,"error":"not_in_channel"}`})
+	ctx := context.WithValue(context.Background(), botTokenKey{}, "xoxb-test")
+
+	_, err := callSlackAPI(ctx, "chat.postMessage", map[string]interface{}{"channel": "#general"})
+	if !needsMembership(err) {
+		t.Errorf("err = %v, want not_in_channel", err)
+	}
+}
+
+func TestSlackbotWebhookChannel(t *testing.T) {
+	doer := &stubDoer{status: http.StatusOK, body: "ok"}
+	stubOutbound(t, doer)
+
+	if err := slackbot(context.Background(), "https://hooks.slack.com/services/T/B/X", "#events", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]interface{}
+	if err := json.NewDecoder(doer.requests[0].Body).Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["channel"] != "#events" || payload["text"] != "hello" {
+		t.Errorf("payload = %v", payload)
+	}
+}
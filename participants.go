@@ -0,0 +1,123 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	participantsKind   = "EventParticipants"
+	textComposition    = "初参加 %d人 / リピーター %d人\n"
+	textFirstTimers    = "初参加の方: %s\n"
+	textNoParticipants = "connpass の参加者一覧を取得できませんでした。\n"
+)
+
+// participantRe finds the connpass users on the public participation page
+var participantRe = regexp.MustCompile(`href="https://connpass\.com/user/([^/"]+)/"`)
+
+// EventParticipants are the connpass nicknames on the participation page of an event, keyed by event ID
+type EventParticipants struct {
+	EventURL  string
+	Nicknames []string `datastore:",noindex"`
+	FetchedAt time.Time
+}
+
+// fetchParticipants reads the nicknames from the public participation page of the event
+func fetchParticipants(ctx context.Context, event Event) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(event.URL, "/")+"/participation/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, cancel := outboundClient(ctx, connpassTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	seen := map[string]bool{}
+	var nicknames []string
+	for _, match := range participantRe.FindAllStringSubmatch(string(body), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			nicknames = append(nicknames, match[1])
+		}
+	}
+
+	return nicknames, nil
+}
+
+// firstTimers stores the participants of the event and returns them with those not seen at earlier events.
+// Only events since then are known, so the first months count everyone as a first-timer.
+func firstTimers(ctx context.Context, event Event) (nicknames, first []string, err error) {
+	nicknames, err = fetchParticipants(ctx, event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	participants := EventParticipants{EventURL: event.URL, Nicknames: nicknames, FetchedAt: time.Now()}
+	if _, err := datastore.Put(ctx, eventKey(ctx, participantsKind, event.URL), &participants); err != nil {
+		log.Errorf(ctx, "participants put %s: %v", event.URL, err)
+	}
+
+	var past []EventParticipants
+	if _, err := datastore.NewQuery(participantsKind).GetAll(ctx, &past); err != nil {
+		return nil, nil, err
+	}
+	known := map[string]bool{}
+	for _, p := range past {
+		if p.EventURL == event.URL {
+			continue
+		}
+		for _, nickname := range p.Nicknames {
+			known[nickname] = true
+		}
+	}
+
+	for _, nickname := range nicknames {
+		if !known[nickname] {
+			first = append(first, nickname)
+		}
+	}
+	return nicknames, first, nil
+}
+
+// composition tells first-timers from repeaters for #manage
+func composition(ctx context.Context, event Event) string {
+	nicknames, first, err := firstTimers(ctx, event)
+	if err != nil {
+		log.Errorf(ctx, "participants %s: %v", event.URL, err)
+		return textNoParticipants
+	}
+
+	text := fmt.Sprintf(textComposition, len(first), len(nicknames)-len(first))
+	if len(first) > 0 {
+		text += fmt.Sprintf(textFirstTimers, strings.Join(first, "、"))
+	}
+	return text
+}
+
+// countFirstTimers returns the number of first-timers for the welcome in the start message, 0 on errors
+func countFirstTimers(ctx context.Context, event Event) int {
+	_, first, err := firstTimers(ctx, event)
+	if err != nil {
+		log.Errorf(ctx, "participants %s: %v", event.URL, err)
+		return 0
+	}
+	return len(first)
+}
@@ -0,0 +1,45 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Partner is a community events are co-hosted with
+type Partner struct {
+	Name       string   `yaml:"name"`
+	SeriesIDs  []int    `yaml:"series_ids"`
+	Keywords   []string `yaml:"keywords"`
+	WebhookURL string   `yaml:"webhook_url"`
+}
+
+// partnerFor detects the co-hosting partner by the series or keywords in the title
+func partnerFor(ctx context.Context, event Event) (Partner, bool) {
+	for _, partner := range currentSettings(ctx).Partners {
+		for _, id := range partner.SeriesIDs {
+			if event.inSeries(id) {
+				return partner, true
+			}
+		}
+		for _, keyword := range partner.Keywords {
+			if keyword != "" && strings.Contains(event.Title, keyword) {
+				return partner, true
+			}
+		}
+	}
+	return Partner{}, false
+}
+
+// crossPost sends the announcement to the incoming webhook of the partner community
+func crossPost(ctx context.Context, w http.ResponseWriter, event Event, text string, blocks ...interface{}) {
+	partner, ok := partnerFor(ctx, event)
+	if !ok || partner.WebhookURL == "" || stagingChannel != "" || inHiatus(ctx, time.Now()) {
+		return
+	}
+	if err := slackbot(ctx, partner.WebhookURL, "", text, blocks...); err != nil {
+		http.Error(w, fmt.Sprintf("partner %s: %v", partner.Name, err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,126 @@
+package slackbot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	roleTokenTTL = 12 * time.Hour
+	textToken    = "%s 権限のトークンです (%s まで有効)。`Authorization: Bearer %s` を付けて /admin/ にリクエストしてください。"
+	textNoRole   = "管理用のトークンを発行する権限がありません。settings.yaml の roles に追加してもらってください。"
+)
+
+// role is a permission level of the admin endpoints, each including the lower ones
+type role int
+
+const (
+	roleNone role = iota
+	// roleViewer may read previews and dead letters
+	roleViewer
+	// roleOrganizer may also resend, retract and tag notifications
+	roleOrganizer
+	// roleAdmin may also change settings, move the state and backfill
+	roleAdmin
+)
+
+var roleNames = map[string]role{
+	"viewer":    roleViewer,
+	"organizer": roleOrganizer,
+	"admin":     roleAdmin,
+}
+
+func (r role) String() string {
+	for name, v := range roleNames {
+		if v == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// signRole returns a token of the role for the Slack user, signed with ADMIN_TOKEN
+func signRole(userID string, r role, expires time.Time) string {
+	payload := fmt.Sprintf("%d|%s|%d", r, userID, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(roleMAC(payload))
+}
+
+func roleMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(adminToken))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// verifyRole returns the role of a token from signRole, roleNone when it is invalid or expired
+func verifyRole(token string, now time.Time) role {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return roleNone
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return roleNone
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sum, roleMAC(string(payload))) {
+		return roleNone
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 {
+		return roleNone
+	}
+	level, err1 := strconv.Atoi(fields[0])
+	expires, err2 := strconv.ParseInt(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || !now.Before(time.Unix(expires, 0)) {
+		return roleNone
+	}
+
+	return role(level)
+}
+
+// requestRole returns the role of the bearer token, ADMIN_TOKEN itself being an admin
+func requestRole(r *http.Request) role {
+	if adminToken == "" {
+		return roleNone
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		return roleAdmin
+	}
+	return verifyRole(token, time.Now())
+}
+
+// hasRole reports whether the request has at least the role
+func hasRole(r *http.Request, need role) bool {
+	return requestRole(r) >= need
+}
+
+func isAdmin(r *http.Request) bool {
+	return hasRole(r, roleAdmin)
+}
+
+// userRole returns the role given to the Slack user in settings.yaml.
+// Roles are shared by all communities of the deployment, so only the top level roles count.
+func userRole(userID string) role {
+	return roleNames[globalSettings().Roles[userID]]
+}
+
+// commandToken handles "/nfug token", issuing a token of the role given to the user in settings.yaml
+func commandToken(userID string) string {
+	r := userRole(userID)
+	if r == roleNone || adminToken == "" {
+		return textNoRole
+	}
+
+	expires := time.Now().Add(roleTokenTTL)
+	return fmt.Sprintf(textToken, r, expires.Format("01/02 15:04"), signRole(userID, r, expires))
+}
@@ -0,0 +1,121 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	photoThreadKind = "PhotoThread"
+	textPhotos      = "写真 %d枚 (%d人から)\n%s\n"
+	textNoPhotos    = "写真の投稿はありませんでした。\n"
+)
+
+// linkRe finds the links Slack escapes in message text, like <https://example.com|label>
+var linkRe = regexp.MustCompile(`<(https?://[^|>]+)(\|[^>]*)?>`)
+
+// PhotoThread is the thread collecting the photos of an event, keyed by channel ID and ts of its parent message
+type PhotoThread struct {
+	EventURL     string
+	ChannelID    string
+	TS           string
+	Photos       []string `datastore:",noindex"`
+	Contributors []string `datastore:",noindex"`
+	CreatedAt    time.Time
+}
+
+func photoThreadKey(ctx context.Context, channelID, ts string) *datastore.Key {
+	return datastore.NewKey(ctx, photoThreadKind, channelID+" "+ts, 0, nil)
+}
+
+// openPhotoThread remembers the posted photo request so that replies to it are collected
+func openPhotoThread(ctx context.Context, event Event, posted slackAPIResponse) {
+	if posted.Channel == "" || posted.TS == "" {
+		return
+	}
+
+	thread := PhotoThread{EventURL: event.URL, ChannelID: posted.Channel, TS: posted.TS, CreatedAt: time.Now()}
+	if _, err := datastore.Put(ctx, photoThreadKey(ctx, posted.Channel, posted.TS), &thread); err != nil {
+		log.Errorf(ctx, "photo thread put %s: %v", event.URL, err)
+	}
+}
+
+// messageFile is a file shared in a message
+// ref: https://api.slack.com/types/file
+type messageFile struct {
+	Mimetype  string `json:"mimetype"`
+	Permalink string `json:"permalink"`
+}
+
+// collectPhotos keeps the images and links posted to a photo thread
+// ref: https://api.slack.com/events/message/message_replied
+func collectPhotos(ctx context.Context, message messageEvent) {
+	if message.ThreadTS == "" || message.ThreadTS == message.TS {
+		return
+	}
+
+	var photos []string
+	for _, file := range message.Files {
+		if strings.HasPrefix(file.Mimetype, "image/") && file.Permalink != "" {
+			photos = append(photos, file.Permalink)
+		}
+	}
+	for _, match := range linkRe.FindAllStringSubmatch(message.Text, -1) {
+		photos = append(photos, match[1])
+	}
+	if len(photos) == 0 {
+		return
+	}
+
+	key := photoThreadKey(ctx, message.Channel, message.ThreadTS)
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var thread PhotoThread
+		if err := datastore.Get(tc, key, &thread); err != nil {
+			return err
+		}
+		thread.Photos = append(thread.Photos, photos...)
+		known := false
+		for _, user := range thread.Contributors {
+			known = known || user == message.User
+		}
+		if !known {
+			thread.Contributors = append(thread.Contributors, message.User)
+		}
+		_, err := datastore.Put(tc, key, &thread)
+		return err
+	}, nil)
+	// replies to any other thread land here too
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "photo thread %s: %v", message.ThreadTS, err)
+	}
+}
+
+// photoSummary lists the photos collected for the event, for the wrap-up in #manage
+func photoSummary(ctx context.Context, event Event) string {
+	var threads []PhotoThread
+	if _, err := datastore.NewQuery(photoThreadKind).Filter("EventURL =", event.URL).GetAll(ctx, &threads); err != nil {
+		log.Errorf(ctx, "photo thread query %s: %v", event.URL, err)
+		return textNoPhotos
+	}
+
+	var photos, contributors []string
+	for _, thread := range threads {
+		photos = append(photos, thread.Photos...)
+		contributors = append(contributors, thread.Contributors...)
+	}
+	if len(photos) == 0 {
+		return textNoPhotos
+	}
+
+	var lines []string
+	for _, photo := range photos {
+		lines = append(lines, "• "+photo)
+	}
+	return fmt.Sprintf(textPhotos, len(photos), len(contributors), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,154 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	planKind         = "EventPlan"
+	planCallbackID   = "new_event_plan"
+	notifyPlanNag    = "plan_nag"
+	planNagEveryDays = 3
+	textPlanTitle    = "新イベント準備"
+	textPlanSummary  = "<@%s> さんが新しいイベントの準備を始めました！\n日程: %s\n会場候補: %s\nテーマ: %s\n"
+	textPlanMissing  = "%s のイベント準備で、まだ決まっていないものがあります: %s\n"
+	textUndecided    = "未定"
+)
+
+// EventPlan is a planned event collected from the "新イベント準備" shortcut,
+// tracked until its connpass page is published
+type EventPlan struct {
+	UserID    string
+	Date      string
+	Venues    []string
+	Theme     string
+	EventURL  string
+	Done      bool
+	NaggedAt  time.Time
+	CreatedAt time.Time
+}
+
+// missing lists what is not decided for the plan yet
+func (p EventPlan) missing() []string {
+	var missing []string
+	if len(p.Venues) == 0 {
+		missing = append(missing, "会場候補")
+	}
+	if p.Theme == "" {
+		missing = append(missing, "テーマ")
+	}
+	if p.EventURL == "" {
+		missing = append(missing, "connpass ページ")
+	}
+	return missing
+}
+
+func (p EventPlan) summary() string {
+	venues, theme := textUndecided, textUndecided
+	if len(p.Venues) > 0 {
+		venues = strings.Join(p.Venues, "、")
+	}
+	if p.Theme != "" {
+		theme = p.Theme
+	}
+	return fmt.Sprintf(textPlanSummary, p.UserID, p.Date, venues, theme)
+}
+
+// planModal is the modal opened by the shortcut
+// ref: https://api.slack.com/reference/surfaces/views
+func planModal() map[string]interface{} {
+	input := func(blockID, label string, element map[string]interface{}, optional bool) map[string]interface{} {
+		element["action_id"] = blockID
+		return map[string]interface{}{
+			"type":     "input",
+			"block_id": blockID,
+			"label":    map[string]interface{}{"type": "plain_text", "text": label},
+			"element":  element,
+			"optional": optional,
+		}
+	}
+
+	return map[string]interface{}{
+		"type":        "modal",
+		"callback_id": planCallbackID,
+		"title":       map[string]interface{}{"type": "plain_text", "text": textPlanTitle},
+		"submit":      map[string]interface{}{"type": "plain_text", "text": "共有する"},
+		"blocks": []interface{}{
+			input("date", "日程", map[string]interface{}{"type": "datepicker"}, false),
+			input("venues", "会場候補 (1行に1つ)", map[string]interface{}{"type": "plain_text_input", "multiline": true}, true),
+			input("theme", "テーマ", map[string]interface{}{"type": "plain_text_input"}, true),
+		},
+	}
+}
+
+// openPlanModal opens the modal for the shortcut
+// ref: https://api.slack.com/methods/views.open
+func openPlanModal(ctx context.Context, triggerID string) error {
+	_, err := callSlackAPI(ctx, "views.open", map[string]interface{}{
+		"trigger_id": triggerID,
+		"view":       planModal(),
+	})
+	return err
+}
+
+// submitPlan stores the submitted plan and shares it with #manage
+func submitPlan(ctx context.Context, userID string, values map[string]map[string]viewStateValue) error {
+	plan := EventPlan{
+		UserID:    userID,
+		Date:      values["date"]["date"].SelectedDate,
+		Theme:     strings.TrimSpace(values["theme"]["theme"].Value),
+		CreatedAt: time.Now(),
+	}
+	for _, venue := range strings.Split(values["venues"]["venues"].Value, "\n") {
+		if venue = strings.TrimSpace(venue); venue != "" {
+			plan.Venues = append(plan.Venues, venue)
+		}
+	}
+
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, planKind, nil), &plan); err != nil {
+		return err
+	}
+
+	_, err := postMessage(ctx, defaultSeriesConfig.ManageChannel, plan.summary())
+	return err
+}
+
+// nagPlans reminds #manage of undecided pieces of plans until the connpass page is published
+func nagPlans(ctx context.Context, w http.ResponseWriter, events []Event, now time.Time) {
+	var plans []EventPlan
+	keys, err := datastore.NewQuery(planKind).Filter("Done =", false).GetAll(ctx, &plans)
+	if err != nil {
+		log.Errorf(ctx, "plan query: %v", err)
+		return
+	}
+
+	for i, plan := range plans {
+		for _, event := range events {
+			if event.StartedAt.Format("2006-01-02") == plan.Date {
+				plan.EventURL = event.URL
+			}
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", plan.Date, time.Local)
+		plan.Done = plan.EventURL != "" || err != nil || date.Before(now)
+
+		nag := !plan.Done && rules.IsRegularTime(now, currentSettings(ctx).RegularHour) &&
+			(plan.NaggedAt.IsZero() || rules.DaysUntil(now, plan.NaggedAt) >= planNagEveryDays)
+		if nag {
+			notify(ctx, w, notifyPlanNag, Event{}, defaultSeriesConfig.ManageChannel, fmt.Sprintf(textPlanMissing, plan.Date, strings.Join(plan.missing(), "、")))
+			plan.NaggedAt = now
+		}
+
+		if _, err := datastore.Put(ctx, keys[i], &plan); err != nil {
+			log.Errorf(ctx, "plan put: %v", err)
+		}
+	}
+}
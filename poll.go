@@ -0,0 +1,240 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	pollKind       = "Poll"
+	pollVoteAction = "poll_vote"
+	textPollTitle  = "次回イベントの日程調整です。参加できる日程をすべて押してください！"
+	textPollNoRole = "日程調整を作る権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+	textPollNoVote = "日程調整を締め切りましたが、投票がありませんでした。"
+)
+
+// Poll is a date-candidate poll for the next event
+type Poll struct {
+	Channel string
+	// ManageChannel receives the result, #manage of the default series for polls created before it was stored
+	ManageChannel string
+	TS            string
+	Candidates    []string
+	Votes         []PollVote
+	Closed        bool
+	CreatedAt     time.Time
+}
+
+// pollSeries is the series the poll is for: that of the next event, or else of the latest one
+func pollSeries(ctx context.Context) SeriesConfig {
+	if event, ok := nextEvent(ctx); ok {
+		return seriesConfigFor(ctx, event)
+	}
+	if event, ok := latestEvent(ctx); ok {
+		return seriesConfigFor(ctx, event)
+	}
+	return defaultSeriesConfig
+}
+
+// PollVote is a vote of a Slack user for one candidate
+type PollVote struct {
+	UserID    string
+	Candidate int
+}
+
+// tally returns the number of votes per candidate
+func (p Poll) tally() []int {
+	counts := make([]int, len(p.Candidates))
+	for _, vote := range p.Votes {
+		if vote.Candidate >= 0 && vote.Candidate < len(counts) {
+			counts[vote.Candidate]++
+		}
+	}
+	return counts
+}
+
+// toggle adds or removes the vote of the user for the candidate
+func (p *Poll) toggle(userID string, candidate int) {
+	for i, vote := range p.Votes {
+		if vote.UserID == userID && vote.Candidate == candidate {
+			p.Votes = append(p.Votes[:i], p.Votes[i+1:]...)
+			return
+		}
+	}
+	p.Votes = append(p.Votes, PollVote{UserID: userID, Candidate: candidate})
+}
+
+// pollBlocks renders the poll with a vote button per candidate
+func pollBlocks(id int64, poll Poll) []interface{} {
+	counts := poll.tally()
+
+	title := textPollTitle
+	if poll.Closed {
+		title = "日程調整は締め切りました。"
+	}
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": title},
+		},
+	}
+
+	for i, candidate := range poll.Candidates {
+		section := map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("*%s*  %d票", candidate, counts[i]),
+			},
+		}
+		if !poll.Closed {
+			section["accessory"] = map[string]interface{}{
+				"type":      "button",
+				"text":      map[string]interface{}{"type": "plain_text", "text": "参加できる"},
+				"action_id": fmt.Sprintf("%s_%d", pollVoteAction, i),
+				"value":     fmt.Sprintf("%d:%d", id, i),
+			}
+		}
+		blocks = append(blocks, section)
+	}
+
+	return blocks
+}
+
+// commandPoll handles "/nfug poll 3/14 3/21 3/28" and "/nfug poll close"
+func commandPoll(ctx context.Context, userID string, args []string) string {
+	if len(args) == 0 {
+		return textCommandUsage
+	}
+	if userRole(userID) < roleOrganizer {
+		return textPollNoRole
+	}
+	if args[0] == "close" {
+		return closePoll(ctx)
+	}
+
+	series := pollSeries(ctx)
+	poll := Poll{
+		Channel:       series.GeneralChannel,
+		ManageChannel: series.ManageChannel,
+		Candidates:    args,
+		CreatedAt:     time.Now(),
+	}
+
+	key, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, pollKind, nil), &poll)
+	if err != nil {
+		return err.Error()
+	}
+
+	ts, err := postMessage(ctx, poll.Channel, textPollTitle, pollBlocks(key.IntID(), poll)...)
+	if err != nil {
+		return err.Error()
+	}
+	poll.TS = ts
+	if _, err := datastore.Put(ctx, key, &poll); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%s に日程調整を作成しました。", poll.Channel)
+}
+
+// closePoll closes the latest open poll and posts the winning date to #manage
+func closePoll(ctx context.Context) string {
+	var polls []Poll
+	keys, err := datastore.NewQuery(pollKind).Filter("Closed =", false).GetAll(ctx, &polls)
+	if err != nil {
+		return err.Error()
+	}
+	if len(polls) == 0 {
+		return "受付中の日程調整はありません。"
+	}
+
+	latest := 0
+	for i := range polls {
+		if polls[i].CreatedAt.After(polls[latest].CreatedAt) {
+			latest = i
+		}
+	}
+	key, poll := keys[latest], polls[latest]
+
+	poll.Closed = true
+	if _, err := datastore.Put(ctx, key, &poll); err != nil {
+		return err.Error()
+	}
+	if err := updateMessage(ctx, poll.Channel, poll.TS, textPollTitle, pollBlocks(key.IntID(), poll)...); err != nil {
+		log.Errorf(ctx, "poll update: %v", err)
+	}
+
+	counts := poll.tally()
+	most := 0
+	for _, count := range counts {
+		if count > most {
+			most = count
+		}
+	}
+	var winners []string
+	for i, count := range counts {
+		if count == most {
+			winners = append(winners, poll.Candidates[i])
+		}
+	}
+
+	text := textPollNoVote
+	switch {
+	case most == 0:
+	case len(winners) == 1:
+		text = fmt.Sprintf("日程調整の結果、次回は *%s* が最多でした (%d票)。", winners[0], most)
+	default:
+		text = fmt.Sprintf("日程調整の結果、*%s* が同数で最多でした (%d票)。どれにするか決めてください。", strings.Join(winners, "*, *"), most)
+	}
+	manage := poll.ManageChannel
+	if manage == "" {
+		manage = defaultSeriesConfig.ManageChannel
+	}
+	if _, err := postMessage(ctx, manage, text); err != nil {
+		return err.Error()
+	}
+
+	return text
+}
+
+// votePoll records a button click on the poll and refreshes the message
+func votePoll(ctx context.Context, userID, value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid vote %q", value)
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	candidate, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NewKey(ctx, pollKind, "", id, nil)
+	var poll Poll
+	err = datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		if err := datastore.Get(tc, key, &poll); err != nil {
+			return err
+		}
+		if poll.Closed {
+			return nil
+		}
+		poll.toggle(userID, candidate)
+		_, err := datastore.Put(tc, key, &poll)
+		return err
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return updateMessage(ctx, poll.Channel, poll.TS, textPollTitle, pollBlocks(id, poll)...)
+}
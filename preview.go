@@ -0,0 +1,75 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+)
+
+// rulePreview is the rendered message of a rule
+type rulePreview struct {
+	Rule   string        `json:"rule"`
+	Text   string        `json:"text"`
+	Blocks []interface{} `json:"blocks"`
+}
+
+// findEventByURL looks up the event in the last fetched connpass response by its URL in any form, or its event ID
+func findEventByURL(ctx context.Context, eventURL string) (Event, bool) {
+	events, err := parseEvents(loadConnpassCache(ctx).Body)
+	if err != nil {
+		return Event{}, false
+	}
+
+	for _, event := range events {
+		if eventKeyName(event.URL) == eventKeyName(eventURL) {
+			return event, true
+		}
+	}
+	return Event{}, false
+}
+
+// handlePreview serves /admin/preview?rule=two_weeks_before&event=<url>, rendering the rule against live event data
+// without posting it. Actions with side effects (Trello cards) are skipped. &variant=b renders the B template of an experiment.
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleViewer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx, err := withCommunity(appengine.NewContext(r), r.URL.Query().Get("community"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	rule, ok := rules.Find(r.URL.Query().Get("rule"))
+	if !ok {
+		http.Error(w, "unknown rule", http.StatusNotFound)
+		return
+	}
+	rule = currentSettings(ctx).rule(rule)
+
+	event, ok := findEventByURL(ctx, r.URL.Query().Get("event"))
+	if !ok {
+		http.Error(w, "unknown event", http.StatusNotFound)
+		return
+	}
+
+	e := ruleEvent(ctx, event, loadSnapshot(ctx, event))
+	e.TimeToEvent = rules.TimeToEvent(event.StartedAt, event.EndedAt, time.Now())
+	if enrich, ok := enrichers[rule.Name]; ok {
+		enrich(ctx, event, &e)
+	}
+
+	text, blocks, err := renderRule(ctx, currentSettings(ctx).variantRule(rule, r.URL.Query().Get("variant")), event, e)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rulePreview{Rule: rule.Name, Text: text, Blocks: blocks})
+}
@@ -0,0 +1,100 @@
+package slackbot
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"google.golang.org/appengine"
+)
+
+const qrCodeSize = 512
+
+var surveyURL = os.Getenv("SURVEY_URL")
+
+// appBaseURL is the public URL of this app
+func appBaseURL(ctx context.Context) string {
+	if shortURLBase != "" {
+		return shortURLBase
+	}
+	return "https://" + appengine.DefaultVersionHostname(ctx)
+}
+
+func qrCodeURL(ctx context.Context, event Event) string {
+	return appBaseURL(ctx) + "/events/" + strconv.Itoa(event.ID) + "/qr.png"
+}
+
+// findEvent returns the event with the connpass event ID from the last fetched events of any community
+func findEvent(ctx context.Context, id int) (Event, bool) {
+	for _, name := range communityNames() {
+		communityCtx, err := withCommunity(ctx, name)
+		if err != nil {
+			continue
+		}
+
+		events, err := parseEvents(loadConnpassCache(communityCtx).Body)
+		if err != nil {
+			continue
+		}
+		for _, event := range events {
+			if event.ID == id {
+				return event, true
+			}
+		}
+	}
+	return Event{}, false
+}
+
+// handleEventQRCode serves /events/{id}/qr.png, or the survey URL with ?target=survey
+func handleEventQRCode(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	path := strings.TrimPrefix(r.URL.Path, "/events/")
+	if !strings.HasSuffix(path, "/qr.png") {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimSuffix(path, "/qr.png"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	event, ok := findEvent(ctx, id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	content := event.URL
+	if r.URL.Query().Get("target") == "survey" && surveyURL != "" {
+		content = surveyURL
+	}
+
+	png, err := qrcode.Encode(content, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+// qrCodeBlocks builds Block Kit blocks with the QR code image of the event
+func qrCodeBlocks(ctx context.Context, text string, event Event) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+		},
+		map[string]interface{}{
+			"type":      "image",
+			"image_url": qrCodeURL(ctx, event),
+			"alt_text":  event.URL,
+		},
+	}
+}
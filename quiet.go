@@ -0,0 +1,153 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const deferredKind = "DeferredNotification"
+
+// clockRange is a daily window such as 23:00-08:00 (minutes from midnight)
+type clockRange struct {
+	From, To int
+}
+
+// dateRange is a yearly window such as 12-28/01-03 (month*100 + day)
+type dateRange struct {
+	From, To int
+}
+
+// DeferredNotification is a notification held back during quiet hours or blackout periods
+type DeferredNotification struct {
+	Type       string
+	Channel    string
+	Text       string `datastore:",noindex"`
+	BlocksJSON string `datastore:",noindex"`
+	EventJSON  string `datastore:",noindex"`
+	CreatedAt  time.Time
+}
+
+// parseQuietHours parses "23:00-08:00,12:00-13:00"
+func parseQuietHours(raw string) ([]clockRange, error) {
+	var ranges []clockRange
+	for _, v := range splitList(raw) {
+		var fh, fm, th, tm int
+		if _, err := fmt.Sscanf(v, "%d:%d-%d:%d", &fh, &fm, &th, &tm); err != nil {
+			return nil, fmt.Errorf("invalid quiet hours %q", v)
+		}
+		ranges = append(ranges, clockRange{From: fh*60 + fm, To: th*60 + tm})
+	}
+	return ranges, nil
+}
+
+// parseBlackoutPeriods parses "12-28/01-03,08-13/08-16"
+func parseBlackoutPeriods(raw string) ([]dateRange, error) {
+	var ranges []dateRange
+	for _, v := range splitList(raw) {
+		var fm, fd, tm, td int
+		if _, err := fmt.Sscanf(v, "%d-%d/%d-%d", &fm, &fd, &tm, &td); err != nil {
+			return nil, fmt.Errorf("invalid blackout period %q", v)
+		}
+		ranges = append(ranges, dateRange{From: fm*100 + fd, To: tm*100 + td})
+	}
+	return ranges, nil
+}
+
+// inRange reports whether v is in [from, to), wrapping around when from > to
+func inRange(v, from, to int) bool {
+	if from <= to {
+		return from <= v && v < to
+	}
+	return from <= v || v < to
+}
+
+func isQuietTime(ctx context.Context, t time.Time) bool {
+	clock := t.Hour()*60 + t.Minute()
+	for _, q := range currentSettings(ctx).quietHours {
+		if inRange(clock, q.From, q.To) {
+			return true
+		}
+	}
+
+	date := int(t.Month())*100 + t.Day()
+	for _, b := range currentSettings(ctx).blackoutPeriods {
+		if inRange(date, b.From, b.To+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCritical reports whether the notification is sent during quiet hours, as it would be late afterwards.
+// Personal reminders are timed before the event they announce, and still wait for the do-not-disturb window of the user.
+func isCritical(kind string) bool {
+	return kind == rules.Start || kind == notifyStream || kind == notifyReminder || kind == notifySubscription
+}
+
+func deferNotification(ctx context.Context, kind string, event Event, channel, text string, blocks []interface{}) {
+	eventJSON, _ := json.Marshal(event)
+	deferred := DeferredNotification{
+		Type:      kind,
+		Channel:   channel,
+		Text:      text,
+		EventJSON: string(eventJSON),
+		CreatedAt: time.Now(),
+	}
+	if len(blocks) > 0 {
+		blocksJSON, _ := json.Marshal(blocks)
+		deferred.BlocksJSON = string(blocksJSON)
+	}
+
+	key := datastore.NewIncompleteKey(ctx, deferredKind, nil)
+	if _, err := datastore.Put(ctx, key, &deferred); err != nil {
+		log.Errorf(ctx, "defer put: %v", err)
+		return
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:   auditDeferred,
+		Type:     kind,
+		Channel:  channel,
+		EventURL: event.URL,
+		Text:     text,
+	})
+}
+
+// flushDeferred sends the notifications held back, if now is an allowed slot
+func flushDeferred(ctx context.Context, w http.ResponseWriter) {
+	if isQuietTime(ctx, time.Now()) {
+		return
+	}
+
+	var deferred []DeferredNotification
+	keys, err := datastore.NewQuery(deferredKind).Order("CreatedAt").GetAll(ctx, &deferred)
+	if err != nil {
+		log.Errorf(ctx, "defer query: %v", err)
+		return
+	}
+
+	for i, d := range deferred {
+		var event Event
+		json.Unmarshal([]byte(d.EventJSON), &event)
+
+		var blocks []interface{}
+		if d.BlocksJSON != "" {
+			json.Unmarshal([]byte(d.BlocksJSON), &blocks)
+		}
+
+		if err := datastore.Delete(ctx, keys[i]); err != nil {
+			log.Errorf(ctx, "defer delete: %v", err)
+			continue
+		}
+
+		notify(ctx, w, d.Type, event, d.Channel, d.Text, blocks...)
+	}
+}
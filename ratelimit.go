@@ -0,0 +1,106 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+const (
+	// rateLimitAttempts is how many times a throttled call is tried
+	rateLimitAttempts = 3
+	// maxRetryAfter is the longest wait within a request; longer waits are left to the next cron run
+	maxRetryAfter = 30 * time.Second
+)
+
+// slackThrottled counts 429 responses of the Slack Web API in this process, reported by /healthz
+var slackThrottled int64
+
+// throttleCountKey is the context key of the counter of the 429 responses of a cron run
+type throttleCountKey struct{}
+
+// withThrottleCount counts the 429 responses of the calls made with ctx in count as well,
+// so that a cron run reports its own and not those of concurrent requests
+func withThrottleCount(ctx context.Context, count *int64) context.Context {
+	return context.WithValue(ctx, throttleCountKey{}, count)
+}
+
+// rateLimitedError is returned when Slack keeps throttling the calls
+type rateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("slack: rate limited, retry after %s", e.RetryAfter)
+}
+
+// retryAfter parses the Retry-After header in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// doSlackRequest sends the request built by newRequest and returns the response body,
+// waiting as told by Retry-After when Slack answers 429
+// ref: https://api.slack.com/docs/rate-limits
+func doSlackRequest(ctx context.Context, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var wait time.Duration
+	for attempt := 0; attempt < rateLimitAttempts; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		client, cancel := outboundClient(ctx, outboundTimeout)
+		resp, err := client.Do(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			cancel()
+			return body, err
+		}
+		resp.Body.Close()
+		cancel()
+
+		atomic.AddInt64(&slackThrottled, 1)
+		if count, ok := ctx.Value(throttleCountKey{}).(*int64); ok {
+			atomic.AddInt64(count, 1)
+		}
+		wait = retryAfter(resp)
+		log.Warningf(ctx, "slack %s: rate limited, retry after %s", req.URL.Path, wait)
+		if wait > maxRetryAfter || sleep(ctx, wait) != nil {
+			break
+		}
+	}
+
+	return nil, &rateLimitedError{RetryAfter: wait}
+}
+
+// sleep waits for d like paceChannel does, and returns early when ctx is done or the process drains on shutdown.
+// A wait cut short by the shutdown returns errSendBudget, so that the notification is deferred to the next cron run.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-drained:
+		return errSendBudget
+	case <-timer.C:
+		return nil
+	}
+}
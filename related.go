@@ -0,0 +1,101 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+)
+
+const (
+	notifyRelatedEvents = "related_events"
+	relatedEventsDays   = 14
+	textRelatedEvents   = "今週の関連イベントです (NFUG 主催ではありません)\n%s"
+	textRelatedEvent    = "• %s『%s』@%s <%s>"
+)
+
+// searchRelatedEvents finds upcoming connpass events matching the keywords outside our series
+// ref: https://connpass.com/about/api/
+func searchRelatedEvents(ctx context.Context, keywords []string, now time.Time) ([]Event, error) {
+	query := url.Values{}
+	for _, keyword := range keywords {
+		query.Add("keyword_or", keyword)
+	}
+	query.Set("count", "30")
+	query.Set("order", "2")
+
+	req, err := http.NewRequest(http.MethodGet, connpassURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, cancel := outboundClient(ctx, connpassTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	events, err := parseEvents(body)
+	if err != nil {
+		return nil, err
+	}
+
+	until := now.AddDate(0, 0, relatedEventsDays)
+	var related []Event
+	for _, event := range events {
+		ours := false
+		for id := range currentSettings(ctx).Series {
+			ours = ours || event.inSeries(id)
+		}
+		if ours {
+			continue
+		}
+		if event.StartedAt.Before(now) || event.StartedAt.After(until) {
+			continue
+		}
+		related = append(related, event)
+	}
+
+	return related, nil
+}
+
+// postRelatedEvents posts the weekly roundup of related external events on Mondays
+func postRelatedEvents(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	keywords := currentSettings(ctx).RelatedKeywords
+	if len(keywords) == 0 || now.Weekday() != time.Monday || !rules.IsRegularTime(now, currentSettings(ctx).RegularHour) {
+		return
+	}
+
+	year, week := now.ISOWeek()
+	period := fmt.Sprintf("%d-W%02d", year, week)
+	if !loadSent(ctx, notifyRelatedEvents, period).LastSentAt.IsZero() {
+		return
+	}
+
+	related, err := searchRelatedEvents(ctx, keywords, now)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("related events: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(related) > 0 {
+		var lines []string
+		for _, event := range related {
+			lines = append(lines, fmt.Sprintf(textRelatedEvent, event.StartedAt.Format("01/02 15:04"), event.Title, event.Place, event.URL))
+		}
+		notify(ctx, w, notifyRelatedEvents, Event{}, defaultSeriesConfig.GeneralChannel, fmt.Sprintf(textRelatedEvents, strings.Join(lines, "\n")))
+	}
+	markSent(ctx, notifyRelatedEvents, period, now)
+}
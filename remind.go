@@ -0,0 +1,136 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	reminderKind     = "AdHocReminder"
+	notifyReminder   = "reminder"
+	textReminder     = ":alarm_clock: <@%s> さんからのリマインダー: %s"
+	textRemindNoRole = "リマインダーを作る権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+)
+
+// slackMentionRe matches an escaped channel or user mention like <#C0123|manage> or <@U0123>
+var slackMentionRe = regexp.MustCompile(`^<[#@]([A-Z0-9]+)(\|[^>]*)?>$`)
+
+// AdHocReminder is a one-off reminder created with "/nfug remind", tied to the next event
+type AdHocReminder struct {
+	EventURL   string
+	EventTitle string
+	UserID     string
+	Channel    string
+	Text       string `datastore:",noindex"`
+	DeliverAt  time.Time
+	Sent       bool
+	CreatedAt  time.Time
+}
+
+// reminderChannel resolves "#manage", "<#C0123|manage>" or "<@U0123>" to a destination
+func reminderChannel(arg string) (string, bool) {
+	if matches := slackMentionRe.FindStringSubmatch(arg); matches != nil {
+		return matches[1], true
+	}
+	if strings.HasPrefix(arg, "#") && len(arg) > 1 {
+		return arg, true
+	}
+	return "", false
+}
+
+// commandRemind handles "/nfug remind 2024-03-13 10:00 #manage text"
+func commandRemind(ctx context.Context, userID string, args []string) string {
+	// the bot posts as itself into any channel
+	if userRole(userID) < roleOrganizer {
+		return textRemindNoRole
+	}
+	if len(args) < 4 {
+		return textCommandUsage
+	}
+
+	deliverAt, err := time.ParseInLocation("2006-01-02 15:04", args[0]+" "+args[1], time.Local)
+	if err != nil {
+		return fmt.Sprintf("「%s %s」は解釈できませんでした。2024-03-13 10:00 のように指定してください。", args[0], args[1])
+	}
+	if !deliverAt.After(time.Now()) {
+		return "過去の日時にはリマインダーを作れません。"
+	}
+
+	channel, ok := reminderChannel(args[2])
+	if !ok {
+		return fmt.Sprintf("「%s」は送信先として解釈できませんでした。#manage のように指定してください。", args[2])
+	}
+
+	reminder := AdHocReminder{
+		UserID:    userID,
+		Channel:   channel,
+		Text:      strings.Join(args[3:], " "),
+		DeliverAt: deliverAt,
+		CreatedAt: time.Now(),
+	}
+	if event, ok := nextEvent(ctx); ok {
+		reminder.EventURL, reminder.EventTitle = event.URL, event.Title
+	}
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, reminderKind, nil), &reminder); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%s に %s へリマインダーを送ります。", deliverAt.Format("1/2 15:04"), args[2])
+}
+
+// deliverReminders sends the due ad-hoc reminders the same way as the notifications of the rules
+func deliverReminders(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	var reminders []AdHocReminder
+	keys, err := datastore.NewQuery(reminderKind).Filter("Sent =", false).GetAll(ctx, &reminders)
+	if err != nil {
+		log.Errorf(ctx, "reminder query: %v", err)
+		return
+	}
+
+	for i, reminder := range reminders {
+		if reminder.DeliverAt.After(now) || !claimReminder(ctx, keys[i]) {
+			continue
+		}
+
+		text := fmt.Sprintf(textReminder, reminder.UserID, reminder.Text)
+		if reminder.EventURL != "" {
+			text += fmt.Sprintf("\n『%s』<%s>", reminder.EventTitle, reminder.EventURL)
+		}
+		// failures are kept by notify as deferred or dead letters
+		notify(ctx, w, notifyReminder, Event{Title: reminder.EventTitle, URL: reminder.EventURL}, reminder.Channel, text)
+	}
+}
+
+// claimReminder marks the reminder sent in a transaction and reports false when it already was,
+// e.g. by an overlapping cron run
+func claimReminder(ctx context.Context, key *datastore.Key) bool {
+	claimed := false
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var reminder AdHocReminder
+		if err := datastore.Get(tc, key, &reminder); err != nil {
+			return err
+		}
+		if reminder.Sent {
+			return nil
+		}
+
+		reminder.Sent = true
+		if _, err := datastore.Put(tc, key, &reminder); err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "reminder claim: %v", err)
+		return false
+	}
+	return claimed
+}
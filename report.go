@@ -0,0 +1,73 @@
+package slackbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// runReport is the machine-readable result of a cron run.
+// It is passed down as the ResponseWriter, so http.Error calls along the way are collected as errors.
+type runReport struct {
+	header http.Header
+	Events []string    `json:"events"`
+	Fired  []firedRule `json:"fired"`
+	Errors []string    `json:"errors"`
+	// Throttled is the number of 429 responses from Slack during the run
+	Throttled int64 `json:"throttled"`
+	// Overflow is the number of notifications deferred to the next run as the send budget ran out
+	Overflow int `json:"overflow"`
+
+	pending []pendingNotification
+	pool    *sendPool
+}
+
+// firedRule is a rule that fired for an event
+type firedRule struct {
+	Rule     string `json:"rule"`
+	EventURL string `json:"event_url"`
+}
+
+func newRunReport() *runReport {
+	return &runReport{
+		header: http.Header{},
+		Events: []string{},
+		Fired:  []firedRule{},
+		Errors: []string{},
+	}
+}
+
+func (r *runReport) Header() http.Header {
+	return r.header
+}
+
+func (r *runReport) Write(b []byte) (int, error) {
+	if message := strings.TrimSpace(string(b)); message != "" {
+		r.Errors = append(r.Errors, message)
+	}
+	return len(b), nil
+}
+
+func (r *runReport) WriteHeader(statusCode int) {}
+
+func (r *runReport) fired(rule, eventURL string) {
+	r.Fired = append(r.Fired, firedRule{Rule: rule, EventURL: eventURL})
+}
+
+// status is 200 on success, 207 when the run partly failed and 500 when nothing could be processed
+func (r *runReport) status() int {
+	switch {
+	case len(r.Errors) == 0:
+		return http.StatusOK
+	case len(r.Events) == 0:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusMultiStatus
+	}
+}
+
+func (r *runReport) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(r.status())
+	json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,47 @@
+package slackbot
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/appengine"
+)
+
+// handleRetract deletes a bot message with POST /admin/retract?channel={channel ID}&ts={ts}
+// and records the retraction in the audit log
+// ref: https://api.slack.com/methods/chat.delete
+func handleRetract(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleOrganizer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := appengine.NewContext(r)
+	ctx = forTeam(ctx, r.FormValue("team"))
+	channel, ts := r.FormValue("channel"), r.FormValue("ts")
+	if channel == "" || ts == "" {
+		http.Error(w, "channel and ts are required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := callSlackAPI(ctx, "chat.delete", map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:    auditRetracted,
+		ChannelID: channel,
+		TS:        ts,
+		Detail:    r.FormValue("reason"),
+	})
+
+	fmt.Fprintln(w, "retracted")
+}
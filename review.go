@@ -0,0 +1,98 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+)
+
+const (
+	notifyYearReview = "year_review"
+	yearReviewDay    = 28
+	textYearReview   = "%d年の振り返りです！\n今年は %d 回のイベントを開催し、のべ %d 人に参加いただきました (平均充足率 %.0f%%)。\n一番人気は『%s』(%d人) でした。\n%s来年もよろしくお願いします！"
+)
+
+// yearReview summarizes the events of the year
+type yearReview struct {
+	Year      int
+	Events    []ArchivedEvent
+	Total     int
+	FillRate  float64
+	Popular   ArchivedEvent
+	NewVenues []string
+}
+
+func buildYearReview(ctx context.Context, year int) yearReview {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(1, 0, 0)
+
+	review := yearReview{Year: year, Events: archivedEvents(ctx, from, to)}
+
+	knownVenues := map[string]bool{}
+	for _, past := range archivedEvents(ctx, time.Time{}, from) {
+		knownVenues[past.Place] = true
+	}
+
+	rated := 0
+	for _, event := range review.Events {
+		review.Total += event.Accepted
+		if event.Limit > 0 {
+			review.FillRate += event.fillRate()
+			rated++
+		}
+		if event.Accepted > review.Popular.Accepted {
+			review.Popular = event
+		}
+		if event.Place != "" && !knownVenues[event.Place] {
+			knownVenues[event.Place] = true
+			review.NewVenues = append(review.NewVenues, event.Place)
+		}
+	}
+	if rated > 0 {
+		review.FillRate /= float64(rated)
+	}
+
+	return review
+}
+
+func (y yearReview) summary() string {
+	venues := ""
+	if len(y.NewVenues) > 0 {
+		venues = fmt.Sprintf("新しい会場: %s\n", strings.Join(y.NewVenues, "、"))
+	}
+	return fmt.Sprintf(textYearReview, y.Year, len(y.Events), y.Total, y.FillRate*100, y.Popular.Title, y.Popular.Accepted, venues)
+}
+
+func (y yearReview) detail() string {
+	lines := []string{fmt.Sprintf("%d年のイベント一覧", y.Year)}
+	for _, event := range y.Events {
+		lines = append(lines, fmt.Sprintf("• %s『%s』%d/%d人 @%s", event.StartedAt.Format("01/02"), event.Title, event.Accepted, event.Limit, event.Place))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// postYearReview posts the year-in-review once in late December
+func postYearReview(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	if now.Month() != time.December || now.Day() != yearReviewDay || !rules.IsRegularTime(now, currentSettings(ctx).RegularHour) {
+		return
+	}
+
+	year := strconv.Itoa(now.Year())
+	if !loadSent(ctx, notifyYearReview, year).LastSentAt.IsZero() {
+		return
+	}
+
+	review := buildYearReview(ctx, now.Year())
+	if len(review.Events) == 0 {
+		return
+	}
+
+	notify(ctx, w, notifyYearReview, Event{}, defaultSeriesConfig.GeneralChannel, review.summary())
+	notify(ctx, w, notifyYearReview, Event{}, defaultSeriesConfig.ManageChannel, review.detail())
+	markSent(ctx, notifyYearReview, year, now)
+}
@@ -0,0 +1,65 @@
+package rules
+
+import (
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// japaneseWeekdays are the weekday marks of Japanese dates
+var japaneseWeekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// Funcs are the helper functions available in templates, e.g. {{jdate .StartedAt}} or {{seats .Accepted .Limit}}.
+// Add to it with RegisterFunc before templates are rendered.
+var Funcs = template.FuncMap{
+	"jdate":     JapaneseDate,
+	"seats":     Seats,
+	"fillEmoji": FillEmoji,
+	"percent":   Percent,
+}
+
+// RegisterFunc makes fn available in templates as name
+func RegisterFunc(name string, fn interface{}) {
+	Funcs[name] = fn
+}
+
+// JapaneseDate renders a date like 3月14日(木) in the local timezone
+func JapaneseDate(t time.Time) string {
+	t = t.In(time.Local)
+	return fmt.Sprintf("%d月%d日(%s)", t.Month(), t.Day(), japaneseWeekdays[t.Weekday()])
+}
+
+// Seats renders the remaining seats like 残り5席, 満席 or 定員なし
+func Seats(accepted, limit int) string {
+	switch {
+	case limit <= 0:
+		return "定員なし"
+	case accepted >= limit:
+		return "満席"
+	default:
+		return fmt.Sprintf("残り%d席", limit-accepted)
+	}
+}
+
+// FillEmoji picks an emoji by accepted/limit: :fire: when nearly full, :smile: over half, :pray: otherwise
+func FillEmoji(accepted, limit int) string {
+	if limit <= 0 {
+		return ":smile:"
+	}
+	switch ratio := float64(accepted) / float64(limit); {
+	case ratio >= 0.9:
+		return ":fire:"
+	case ratio >= 0.5:
+		return ":smile:"
+	default:
+		return ":pray:"
+	}
+}
+
+// Percent renders accepted/limit as a percentage like 75%
+func Percent(accepted, limit int) string {
+	if limit <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(accepted)/float64(limit)*100)
+}
@@ -0,0 +1,60 @@
+package rules
+
+import "time"
+
+// Stage is a step of the event lifecycle.
+// Rules bound to a stage fire only while the event is in it, so guards like
+// "no more promotion once the final call started" needn't repeat date checks.
+type Stage string
+
+// stages in lifecycle order
+const (
+	Announced Stage = "announced"
+	Promoted  Stage = "promoted"
+	FinalCall Stage = "final_call"
+	Live      Stage = "live"
+	WrapUp    Stage = "wrap_up"
+	Archived  Stage = "archived"
+)
+
+// Stages lists the stages in lifecycle order
+var Stages = []Stage{Announced, Promoted, FinalCall, Live, WrapUp, Archived}
+
+func (s Stage) index() int {
+	for i, stage := range Stages {
+		if stage == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// scheduledStage is the stage the schedule of the event has reached by now
+func scheduledStage(e Event, now time.Time) Stage {
+	days := DaysUntil(e.StartedAt, now)
+	switch {
+	case days < -1:
+		// after the next-day message
+		return Archived
+	case !now.Before(e.EndedAt):
+		return WrapUp
+	case IsStarted(e.StartedAt, now):
+		return Live
+	case days <= 2:
+		return FinalCall
+	case days <= 14:
+		return Promoted
+	default:
+		return Announced
+	}
+}
+
+// Advance returns the stage the event moves to from current.
+// Stages only move forward, so a rescheduled event doesn't repeat what it went through.
+func Advance(current Stage, e Event, now time.Time) Stage {
+	next := scheduledStage(e, now)
+	if next.index() < current.index() {
+		return current
+	}
+	return next
+}
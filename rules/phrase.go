@@ -0,0 +1,29 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeToEvent renders how far the event is from now, e.g. 「あと3日」「本日19:00開始」, in the local timezone
+func TimeToEvent(start, end, now time.Time) string {
+	return TimeToEventIn(start, end, now, time.Local)
+}
+
+// TimeToEventIn is TimeToEvent in the timezone of a reader
+func TimeToEventIn(start, end, now time.Time, loc *time.Location) string {
+	start, now = start.In(loc), now.In(loc)
+
+	switch days := DaysUntil(start, now); {
+	case days > 1:
+		return fmt.Sprintf("あと%d日", days)
+	case days == 1:
+		return "明日" + start.Format("15:04") + "開始"
+	case days == 0 && now.Before(start):
+		return "本日" + start.Format("15:04") + "開始"
+	case now.Before(end):
+		return "開催中"
+	default:
+		return "終了"
+	}
+}
@@ -0,0 +1,41 @@
+package rules
+
+import "time"
+
+// IsStarted reports whether startTime has come.
+// Combined with the sent record it fires once regardless of the cron cadence.
+func IsStarted(startTime, now time.Time) bool {
+	return !now.Before(startTime)
+}
+
+// IsWithin reports whether now is within d after t
+func IsWithin(t, now time.Time, d time.Duration) bool {
+	return !t.IsZero() && !now.Before(t) && now.Sub(t) < d
+}
+
+// IsRegularTime reports whether now is within the regular hour
+func IsRegularTime(now time.Time, hour int) bool {
+	regularTime := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	afterOneHour := regularTime.Add(time.Hour)
+
+	return regularTime.Before(now) && afterOneHour.After(now)
+}
+
+// IsDaysBefore reports whether now is the given days before target
+func IsDaysBefore(target, now time.Time, days int) bool {
+	return DaysUntil(target, now) == days
+}
+
+// IsQuietEvent reports whether at most ratio of the limit is accepted
+func IsQuietEvent(accepted, limit int, ratio float64) bool {
+	return float64(accepted)/float64(limit) <= ratio
+}
+
+// DaysUntil returns the number of calendar days from now to target
+func DaysUntil(target, now time.Time) int {
+	target = target.In(now.Location())
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := time.Date(target.Year(), target.Month(), target.Day(), 0, 0, 0, 0, now.Location())
+
+	return int(to.Sub(from).Hours() / 24)
+}
@@ -0,0 +1,139 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+func at(year int, month time.Month, day, hour, min int) time.Time {
+	return time.Date(year, month, day, hour, min, 0, 0, jst)
+}
+
+func TestIsDaysBefore(t *testing.T) {
+	tests := []struct {
+		name   string
+		target time.Time
+		now    time.Time
+		days   int
+		want   bool
+	}{
+		{"same day", at(2024, 3, 14, 19, 0), at(2024, 3, 14, 10, 0), 0, true},
+		{"two days before", at(2024, 3, 14, 19, 0), at(2024, 3, 12, 19, 30), 2, true},
+		{"one day off", at(2024, 3, 14, 19, 0), at(2024, 3, 13, 19, 30), 2, false},
+		{"two weeks before across the year", at(2025, 1, 8, 19, 0), at(2024, 12, 25, 19, 0), 14, true},
+		{"one week before across the year", at(2025, 1, 3, 19, 0), at(2024, 12, 27, 19, 0), 7, true},
+		{"same year day of another year", at(2025, 3, 14, 19, 0), at(2024, 3, 14, 19, 0), 0, false},
+		{"leap day", at(2024, 3, 1, 19, 0), at(2024, 2, 28, 19, 0), 2, true},
+		{"target in another timezone", at(2024, 3, 14, 0, 30).In(time.UTC), at(2024, 3, 13, 19, 0), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDaysBefore(tt.target, tt.now, tt.days); got != tt.want {
+				t.Errorf("IsDaysBefore(%s, %s, %d) = %v, want %v", tt.target, tt.now, tt.days, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysUntil(t *testing.T) {
+	tests := []struct {
+		name   string
+		target time.Time
+		now    time.Time
+		want   int
+	}{
+		{"same day", at(2024, 3, 14, 23, 0), at(2024, 3, 14, 0, 0), 0},
+		{"tomorrow", at(2024, 3, 15, 0, 0), at(2024, 3, 14, 23, 59), 1},
+		{"across the year", at(2025, 1, 3, 19, 0), at(2024, 12, 30, 19, 0), 4},
+		{"past", at(2024, 3, 12, 19, 0), at(2024, 3, 14, 19, 0), -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DaysUntil(tt.target, tt.now); got != tt.want {
+				t.Errorf("DaysUntil(%s, %s) = %d, want %d", tt.target, tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRegularTime(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"before", at(2024, 3, 14, 18, 59), false},
+		{"within", at(2024, 3, 14, 19, 30), true},
+		{"after", at(2024, 3, 14, 20, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRegularTime(tt.now, 19); got != tt.want {
+				t.Errorf("IsRegularTime(%s, 19) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStarted(t *testing.T) {
+	start := at(2024, 3, 14, 19, 0)
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"before", start.Add(-time.Minute), false},
+		{"at the start", start, true},
+		{"after", start.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsStarted(start, tt.now); got != tt.want {
+				t.Errorf("IsStarted(%s, %s) = %v, want %v", start, tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	end := at(2024, 3, 14, 21, 0)
+	tests := []struct {
+		name string
+		t    time.Time
+		now  time.Time
+		want bool
+	}{
+		{"zero time", time.Time{}, end, false},
+		{"before", end, end.Add(-time.Minute), false},
+		{"within", end, end.Add(2 * time.Hour), true},
+		{"after", end, end.Add(3 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsWithin(tt.t, tt.now, 3*time.Hour); got != tt.want {
+				t.Errorf("IsWithin(%s, %s, 3h) = %v, want %v", tt.t, tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsQuietEvent(t *testing.T) {
+	tests := []struct {
+		name            string
+		accepted, limit int
+		want            bool
+	}{
+		{"few", 5, 30, true},
+		{"at the ratio", 15, 30, true},
+		{"many", 20, 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsQuietEvent(tt.accepted, tt.limit, 0.5); got != tt.want {
+				t.Errorf("IsQuietEvent(%d, %d, 0.5) = %v, want %v", tt.accepted, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
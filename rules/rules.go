@@ -0,0 +1,563 @@
+// Package rules defines when and how event notifications are posted.
+// Each rule is a plain value so that new rules can be added declaratively
+// and predicates can be evaluated in isolation.
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// rule names, also used as notification types
+const (
+	RegistrationOpened = "registration_opened"
+	TwoWeeksBefore     = "two_weeks_before"
+	Promotion          = "promotion"
+	TalksUnfilled      = "talks_unfilled"
+	OneWeekBefore      = "one_week_before"
+	TwoDaysBefore      = "two_days_before"
+	Lineup             = "lineup"
+	Start              = "start"
+	NextDay            = "next_day"
+	CapacityWarning    = "capacity_warning"
+	WaitlistEscalation = "waitlist_escalation"
+	TweetPrompt        = "tweet_prompt"
+	VenueMismatch      = "venue_mismatch"
+	PaymentReminder    = "payment_reminder"
+	Participants       = "participants"
+	MorningOf          = "morning_of"
+	FeePreparation     = "fee_preparation"
+	PhotoThread        = "photo_thread"
+	PhotoSummary       = "photo_summary"
+	Overbooked         = "overbooked"
+	TicketUnfilled     = "ticket_unfilled"
+)
+
+// limits below this ratio of the usual limit at the venue look like a mistake
+const lowLimitRatio = 0.5
+
+// events starting before eveningHour are daytime events, and those lasting longEvent or more get notes on the duration
+const (
+	eveningHour = 17
+	longEvent   = 4 * time.Hour
+)
+
+// destination channels, resolved per series
+const (
+	General = "general"
+	Manage  = "manage"
+)
+
+// Ticket is the count of a participation type of the connpass event, like 一般枠 or LT枠
+type Ticket struct {
+	Name     string `json:"name"`
+	Accepted int    `json:"accepted"`
+	Limit    int    `json:"limit"`
+	Waiting  int    `json:"waiting"`
+}
+
+// Event is what rules look at and templates render
+type Event struct {
+	Title                string
+	URL                  string
+	Place                string
+	StartedAt            time.Time
+	EndedAt              time.Time
+	Limit                int
+	Accepted             int
+	Waiting              int
+	RegistrationOpenedAt time.Time
+	Hashtag              string
+	HashtagURL           string
+	TweetURL             string
+	Twitter              string
+	IntroChannel         string
+	Talks                int
+	Slots                int
+	Lineup               string
+	Partner              string
+	AccessNotes          string
+	TimeToEvent          string
+	Owner                string
+	RegularHour          int
+	QuietRatio           float64
+	CapacityRatio        float64
+	WaitlistRatio        float64
+	VenueCapacity        int
+	UsualLimit           int
+	Stage                Stage
+	AnnounceGrace        time.Duration
+	Tags                 []string
+	Payment              string
+	ParticipantSummary   bool
+	MorningHour          int
+	DoorOpen             time.Duration
+	Tickets              []Ticket
+	// WatchedTickets are parts of the ticket names alerted when unfilled close to the event
+	WatchedTickets []string
+
+	// filled only for fired rules
+	Headcount        string
+	TaskURL          string
+	PreviousAccepted int
+	Forecast         string
+	HashtagActivity  string
+	// AcceptedDelta is the change of the accepted count since the rule last fired
+	AcceptedDelta int
+	Composition   string
+	Weather       string
+	Photos        string
+	Streamed      bool
+	// FirstTimers is the number of participants not seen at earlier events
+	FirstTimers int
+}
+
+// Quiet reports whether the event has few participants
+func (e Event) Quiet() bool {
+	return IsQuietEvent(e.Accepted, e.Limit, e.QuietRatio)
+}
+
+// NearlyFull reports whether the accepted ratio reached CapacityRatio, but not beyond the limit
+func (e Event) NearlyFull() bool {
+	return e.Limit > 0 && float64(e.Accepted)/float64(e.Limit) >= e.CapacityRatio && !e.Overbooked()
+}
+
+// Overbooked reports whether more participants are accepted than the limit, e.g. after the limit was lowered
+func (e Event) Overbooked() bool {
+	return e.Limit > 0 && e.Accepted > e.Limit
+}
+
+// OverLimit is the number of accepted participants beyond the limit
+func (e Event) OverLimit() int {
+	return e.Accepted - e.Limit
+}
+
+// LongWaitlist reports whether the waitlist exceeds WaitlistRatio of the limit
+func (e Event) LongWaitlist() bool {
+	return e.Limit > 0 && float64(e.Waiting)/float64(e.Limit) > e.WaitlistRatio
+}
+
+// OverCapacity reports whether the limit exceeds the capacity of the venue
+func (e Event) OverCapacity() bool {
+	return e.VenueCapacity > 0 && e.Limit > e.VenueCapacity
+}
+
+// LowLimit reports whether the limit is much lower than usual for the venue
+func (e Event) LowLimit() bool {
+	return e.Limit > 0 && e.UsualLimit > 0 && float64(e.Limit) < float64(e.UsualLimit)*lowLimitRatio
+}
+
+// DoorOpenAt is the clock time the doors open, DoorOpen before the start
+func (e Event) DoorOpenAt() string {
+	return e.StartedAt.Add(-e.DoorOpen).Format("15:04")
+}
+
+// Weekend reports whether the event starts on Saturday or Sunday
+func (e Event) Weekend() bool {
+	weekday := e.StartedAt.In(time.Local).Weekday()
+	return weekday == time.Saturday || weekday == time.Sunday
+}
+
+// Daytime reports whether the event starts before the evening
+func (e Event) Daytime() bool {
+	return e.StartedAt.In(time.Local).Hour() < eveningHour
+}
+
+// OverLunch reports whether the event runs through lunchtime, from before noon until after 13:00
+func (e Event) OverLunch() bool {
+	start := e.StartedAt.In(time.Local)
+	noon := time.Date(start.Year(), start.Month(), start.Day(), 12, 0, 0, 0, time.Local)
+	return start.Before(noon) && e.EndedAt.After(noon.Add(time.Hour))
+}
+
+// LongEvent reports whether the event lasts longEvent or more, like a hands-on
+func (e Event) LongEvent() bool {
+	return e.EndedAt.Sub(e.StartedAt) >= longEvent
+}
+
+// Duration renders the length of the event like 6時間 or 2時間30分
+func (e Event) Duration() string {
+	d := e.EndedAt.Sub(e.StartedAt).Round(time.Minute)
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	if minutes == 0 {
+		return fmt.Sprintf("%d時間", hours)
+	}
+	return fmt.Sprintf("%d時間%d分", hours, minutes)
+}
+
+// UnfilledTickets are the watched tickets with seats left
+func (e Event) UnfilledTickets() []Ticket {
+	var unfilled []Ticket
+	for _, ticket := range e.Tickets {
+		for _, watched := range e.WatchedTickets {
+			if strings.Contains(ticket.Name, watched) && ticket.Accepted < ticket.Limit {
+				unfilled = append(unfilled, ticket)
+				break
+			}
+		}
+	}
+	return unfilled
+}
+
+// TicketBreakdown renders the counts per ticket like 一般枠 12/30人、LT枠 2/5人 (補欠1人)
+func (e Event) TicketBreakdown() string {
+	var parts []string
+	for _, ticket := range e.Tickets {
+		part := fmt.Sprintf("%s %d/%d人", ticket.Name, ticket.Accepted, ticket.Limit)
+		if ticket.Waiting > 0 {
+			part += fmt.Sprintf(" (補欠%d人)", ticket.Waiting)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "、")
+}
+
+// UnfilledSlots is the number of program slots without a talk
+func (e Event) UnfilledSlots() int {
+	return e.Slots - e.Talks
+}
+
+// Repeat controls how often a rule may fire for the same event.
+// The zero value fires once.
+type Repeat struct {
+	// EveryDays is the interval in days between repeats, 0 for once
+	EveryDays int `json:"every_days"`
+	// Until stops repeating once it holds
+	Until func(e Event, now time.Time) bool `json:"-"`
+}
+
+// Rule is a notification rule
+type Rule struct {
+	Name      string                            `json:"name"`
+	Predicate func(e Event, now time.Time) bool `json:"-"`
+	Channel   string                            `json:"channel"`
+	Template  string                            `json:"template"`
+	Repeat    Repeat                            `json:"repeat"`
+	// Stage limits the rule to events in the lifecycle stage, any stage when empty
+	Stage Stage `json:"stage,omitempty"`
+
+	// Announcement rules are posted with the event image and cross-posted to the co-hosting partner
+	Announcement bool `json:"announcement"`
+	// QRCode rules are posted with a QR code of the event URL
+	QRCode bool `json:"qr_code"`
+	// AfterEnd rules are also evaluated for ended events
+	AfterEnd bool `json:"after_end"`
+	// OptIn rules fire only for events with a tag enabling them
+	OptIn bool `json:"opt_in"`
+
+	// Username and IconEmoji override the bot identity, only with the Web API
+	Username  string `json:"username,omitempty"`
+	IconEmoji string `json:"icon_emoji,omitempty"`
+}
+
+// bot identities distinguishing message types
+const (
+	announcer = "NFUG お知らせ"
+	organizer = "NFUG 運営"
+)
+
+// InStage reports whether the event is in the stage of the rule
+func (r Rule) InStage(e Event) bool {
+	return r.Stage == "" || r.Stage == e.Stage
+}
+
+// Due reports whether the rule may fire again, given when it last fired for the event
+func (r Rule) Due(e Event, lastSentAt, now time.Time) bool {
+	if r.Repeat.Until != nil && r.Repeat.Until(e, now) {
+		return false
+	}
+	if lastSentAt.IsZero() {
+		return true
+	}
+	if r.Repeat.EveryDays == 0 {
+		return false
+	}
+
+	return DaysUntil(now, lastSentAt) >= r.Repeat.EveryDays
+}
+
+// Render executes the template of the rule against the event
+func (r Rule) Render(e Event) (string, error) {
+	t, err := template.New(r.Name).Funcs(Funcs).Parse(r.Template)
+	if err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, e); err != nil {
+		return "", err
+	}
+
+	return buffer.String(), nil
+}
+
+// Rules are evaluated in this order
+var Rules = []Rule{
+	{
+		Name: PhotoThread,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsWithin(e.EndedAt, now, 3*time.Hour)
+		},
+		Stage:     WrapUp,
+		Channel:   General,
+		Template:  "『{{.Title}}』お疲れさまでした！:camera_with_flash: このスレッドに当日の写真を投稿してください。人の顔が分かる写真は、写っている方の了承を得てからお願いします。\n",
+		AfterEnd:  true,
+		Username:  announcer,
+		IconEmoji: ":camera:",
+	},
+	{
+		Name: PhotoSummary,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, -1)
+		},
+		Stage:     WrapUp,
+		Channel:   Manage,
+		Template:  "『{{.Title}}』の写真のスレッドに投稿された写真です。ブログやレポートに使う前に、写っている方に確認しましょう。\n{{.Photos}}",
+		AfterEnd:  true,
+		Username:  organizer,
+		IconEmoji: ":frame_with_picture:",
+	},
+	{
+		Name: NextDay,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, -1)
+		},
+		Stage:     WrapUp,
+		Channel:   General,
+		Template:  "『{{.Title}}』昨日のイベントお疲れさまでした。参加者は{{.Accepted}}人でした！\nイベントページ: {{.URL}}\nツイートの振り返り: {{.HashtagURL}}\n{{.HashtagActivity}}ブログを書いた方はぜひ共有してください！次のイベントが立っていなければ用意しましょう！\n",
+		AfterEnd:  true,
+		Username:  announcer,
+		IconEmoji: ":tada:",
+	},
+	{
+		Name: RegistrationOpened,
+		Predicate: func(e Event, now time.Time) bool {
+			// limited seats fill quickly, so this doesn't wait for the regular hour,
+			// only for the grace period in which organizers tend to fix what they just published
+			return e.Limit > 0 && !e.RegistrationOpenedAt.IsZero() && IsWithin(e.RegistrationOpenedAt.Add(e.AnnounceGrace), now, 24*time.Hour)
+		},
+		Channel:   General,
+		Template:  "『{{.Title}}』申し込み開始！定員{{.Limit}}人です。お早めにどうぞ！ <{{.URL}}>\n",
+		Username:  announcer,
+		IconEmoji: ":loudspeaker:",
+	},
+	{
+		Name: TwoWeeksBefore,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 14)
+		},
+		Stage:        Promoted,
+		Channel:      General,
+		Template:     "【{{.TimeToEvent}}】『{{.Title}}』{{if .Quiet}}2週間前になりました。参加者が少し少ないようです。みんなで宣伝しましょう！{{else}}2週間前になりました。参加者はそれなりに多いようです。やったね！{{end}} <{{.URL}}>\n{{if .Partner}}今回は {{.Partner}} さんとの共催です！\n{{end}}{{if .PreviousAccepted}}前回は{{.PreviousAccepted}}人参加でした。現在{{.Accepted}}人！\n{{end}}",
+		Announcement: true,
+		Username:     announcer,
+		IconEmoji:    ":loudspeaker:",
+	},
+	{
+		Name: Promotion,
+		Predicate: func(e Event, now time.Time) bool {
+			// the final call ends the promotion through the stage
+			return IsRegularTime(now, e.RegularHour) && DaysUntil(e.StartedAt, now) < 14 && e.Quiet()
+		},
+		Stage:    Promoted,
+		Channel:  General,
+		Template: "【{{.TimeToEvent}}】『{{.Title}}』まだ参加者が少なめです (現在{{.Accepted}}/{{.Limit}}人)。{{if gt .AcceptedDelta 0}}前回から+{{.AcceptedDelta}}人！{{end}}SNS での宣伝にご協力ください！ <{{.URL}}>\n",
+		Repeat: Repeat{
+			EveryDays: 3,
+			Until: func(e Event, now time.Time) bool {
+				return !e.Quiet()
+			},
+		},
+		Username:  announcer,
+		IconEmoji: ":mega:",
+	},
+	{
+		Name: TalksUnfilled,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 14) && e.UnfilledSlots() > 0
+		},
+		Stage:     Promoted,
+		Channel:   Manage,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』2週間前ですが、発表枠があと{{.UnfilledSlots}}枠空いています。登壇者を探しましょう！ (/nfug talk で登録)\n{{if .Owner}}connpass の作成者: {{.Owner}}\n{{end}}",
+		Username:  organizer,
+		IconEmoji: ":hammer_and_wrench:",
+	},
+	{
+		Name: CapacityWarning,
+		Predicate: func(e Event, now time.Time) bool {
+			return DaysUntil(e.StartedAt, now) > 7 && e.NearlyFull()
+		},
+		Channel:   Manage,
+		Template:  "『{{.Title}}』1週間以上前ですが、参加者が定員に近づいています ({{.Accepted}}/{{.Limit}}人)。定員を増やすか、キャンセル待ちの対応を検討しましょう。\n",
+		Username:  organizer,
+		IconEmoji: ":hammer_and_wrench:",
+	},
+	{
+		Name: WaitlistEscalation,
+		Predicate: func(e Event, now time.Time) bool {
+			return e.LongWaitlist()
+		},
+		Channel:   Manage,
+		Template:  "『{{.Title}}』キャンセル待ちが{{.Waiting}}人 (定員{{.Limit}}人) になっています！会場の変更や追加の枠を至急検討してください。\n{{if .Tickets}}枠ごとの内訳: {{.TicketBreakdown}}\n{{end}}",
+		Username:  organizer,
+		IconEmoji: ":rotating_light:",
+	},
+	{
+		Name: Overbooked,
+		Predicate: func(e Event, now time.Time) bool {
+			return e.Overbooked()
+		},
+		Channel:  Manage,
+		Template: "『{{.Title}}』参加者{{.Accepted}}人が定員{{.Limit}}人を{{.OverLimit}}人上回っています{{if .VenueCapacity}} (会場の収容人数は{{.VenueCapacity}}人){{end}}。定員を減らした場合は、元に戻すか、参加者へのキャンセルのお願い・オンライン参加への切り替え・会場の変更を検討してください。\n",
+		Repeat: Repeat{
+			EveryDays: 1,
+			Until: func(e Event, now time.Time) bool {
+				return !e.Overbooked()
+			},
+		},
+		Username:  organizer,
+		IconEmoji: ":rotating_light:",
+	},
+	{
+		Name: TicketUnfilled,
+		Predicate: func(e Event, now time.Time) bool {
+			days := DaysUntil(e.StartedAt, now)
+			return IsRegularTime(now, e.RegularHour) && days >= 0 && days <= 3 && now.Before(e.StartedAt) && len(e.UnfilledTickets()) > 0
+		},
+		Channel:  Manage,
+		Template: "【{{.TimeToEvent}}】『{{.Title}}』まだ埋まっていない枠があります。{{range .UnfilledTickets}}\n• {{.Name}} {{.Accepted}}/{{.Limit}}人 ({{seats .Accepted .Limit}}){{end}}\n声をかけられそうな方がいないか探してみてください！\n",
+		Repeat: Repeat{
+			EveryDays: 1,
+			Until: func(e Event, now time.Time) bool {
+				return len(e.UnfilledTickets()) == 0
+			},
+		},
+		Username:  organizer,
+		IconEmoji: ":microphone:",
+	},
+	{
+		Name: VenueMismatch,
+		Predicate: func(e Event, now time.Time) bool {
+			return e.OverCapacity() || e.LowLimit()
+		},
+		Channel:   Manage,
+		Template:  "『{{.Title}}』connpass の定員 ({{.Limit}}人) を確認してください。{{if .OverCapacity}}会場『{{.Place}}』の収容人数は{{.VenueCapacity}}人です。{{else}}会場『{{.Place}}』ではいつも{{.UsualLimit}}人前後です。{{end}}\n",
+		Username:  organizer,
+		IconEmoji: ":hammer_and_wrench:",
+	},
+	{
+		Name: OneWeekBefore,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 7)
+		},
+		Stage:     Promoted,
+		Channel:   Manage,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』1週間前になりました。次回の会場が決まっていない場合は検討しましょう。\n{{.Headcount}}{{.Forecast}}{{if .TaskURL}}会場確保のタスク: <{{.TaskURL}}>\n{{end}}{{if .Owner}}会場や説明文の更新は connpass の作成者 {{.Owner}} さんへ\n{{end}}",
+		Username:  organizer,
+		IconEmoji: ":hammer_and_wrench:",
+	},
+	{
+		Name: TwoDaysBefore,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 2)
+		},
+		Stage:        FinalCall,
+		Channel:      General,
+		Template:     "【{{.TimeToEvent}}】『{{.Title}}』2日前です。当日参加できないことが分かっている方は、前日までにキャンセルしましょう。 <{{.URL}}>\n{{if .Partner}}{{.Partner}} さんとの共催です。\n{{end}}{{if .LongEvent}}{{.Duration}}の長めのイベントです。{{if .OverLunch}}お昼休憩があるので、昼食の用意をお願いします。{{end}}\n{{end}}{{.AccessNotes}}{{.Headcount}}",
+		Announcement: true,
+		Username:     announcer,
+		IconEmoji:    ":loudspeaker:",
+	},
+	{
+		Name: Lineup,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 2) && e.Talks > 0
+		},
+		Stage:     FinalCall,
+		Channel:   General,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』発表ラインナップです！\n{{.Lineup}}\n",
+		Username:  announcer,
+		IconEmoji: ":microphone:",
+	},
+	{
+		Name: FeePreparation,
+		Predicate: func(e Event, now time.Time) bool {
+			return e.Payment != "" && IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 2)
+		},
+		Stage:     FinalCall,
+		Channel:   Manage,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』は当日に集金があります ({{.Payment}})。参加者{{.Accepted}}人分のお釣りと、支払い用の QR コードの準備をお願いします！\n",
+		Username:  organizer,
+		IconEmoji: ":moneybag:",
+	},
+	{
+		Name: PaymentReminder,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 2)
+		},
+		Stage:     FinalCall,
+		Channel:   General,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』は会費制です。{{if .Payment}}{{.Payment}}\n{{else}}お支払い方法はイベントページをご確認ください！ {{end}}<{{.URL}}>\n",
+		OptIn:     true,
+		Username:  announcer,
+		IconEmoji: ":moneybag:",
+	},
+	{
+		Name: Participants,
+		Predicate: func(e Event, now time.Time) bool {
+			return e.ParticipantSummary && IsRegularTime(now, e.RegularHour) && IsDaysBefore(e.StartedAt, now, 1)
+		},
+		Stage:     FinalCall,
+		Channel:   Manage,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』明日の参加者の構成です (参加者{{.Accepted}}人)。自己紹介やアイスブレイクの準備に使ってください。\n{{if .Tickets}}枠ごとの内訳: {{.TicketBreakdown}}\n{{end}}{{.Composition}}",
+		Username:  organizer,
+		IconEmoji: ":busts_in_silhouette:",
+	},
+	{
+		Name: MorningOf,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsRegularTime(now, e.MorningHour) && IsDaysBefore(e.StartedAt, now, 0) && now.Before(e.StartedAt)
+		},
+		Stage:     FinalCall,
+		Channel:   General,
+		Template:  "【{{.TimeToEvent}}】『{{.Title}}』は今日です！\n開場 {{.DoorOpenAt}} / 開始 {{.StartedAt.Format \"15:04\"}}{{if .LongEvent}} / 終了 {{.EndedAt.Format \"15:04\"}} ({{.Duration}}){{end}}\n{{if .OverLunch}}お昼休憩があります。昼食をお忘れなく！\n{{end}}会場: {{.Place}}\n{{.AccessNotes}}{{if .Streamed}}オンライン配信もあります (URL は開始時に参加表明した方へお送りします)\n{{end}}{{.Weather}}<{{.URL}}>\n",
+		Username:  announcer,
+		IconEmoji: ":sunny:",
+	},
+	{
+		Name: Start,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsStarted(e.StartedAt, now)
+		},
+		Stage:     Live,
+		Channel:   General,
+		Template:  "『{{.Title}}』イベントスタートです！\nTwitter のハッシュタグ #{{.Hashtag}} ({{.HashtagURL}}) もご活用ください！\nワンタップでツイート: {{.TweetURL}}\n{{if .Twitter}}公式アカウント @{{.Twitter}} のフォローもお願いします！ https://twitter.com/{{.Twitter}}\n{{end}}{{if .Payment}}受付でのお支払い: {{.Payment}}\n{{end}}{{if .FirstTimers}}今日は初参加の方が{{.FirstTimers}}人います。ようこそ！{{if .IntroChannel}}よければ {{.IntroChannel}} で自己紹介してください！{{end}}\n{{end}}{{.AccessNotes}}",
+		QRCode:    true,
+		Username:  announcer,
+		IconEmoji: ":rocket:",
+	},
+	{
+		Name: TweetPrompt,
+		Predicate: func(e Event, now time.Time) bool {
+			return IsStarted(e.StartedAt.Add(e.EndedAt.Sub(e.StartedAt)/2), now)
+		},
+		Stage:     Live,
+		Channel:   General,
+		Template:  "『{{.Title}}』も折り返しです！感想や気になった発表をぜひツイートしてください #{{.Hashtag}}\nワンタップでツイート: {{.TweetURL}}\n",
+		Username:  announcer,
+		IconEmoji: ":bird:",
+	},
+}
+
+// Find returns the rule named name
+func Find(name string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
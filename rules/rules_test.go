@@ -0,0 +1,77 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkEvents are a cron run's worth of events for many communities, starting over the next weeks
+func benchmarkEvents(n int, now time.Time) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		start := now.AddDate(0, 0, i%21).Add(time.Duration(i%3) * time.Hour)
+		events[i] = Event{
+			Title:         fmt.Sprintf("NFUG #%d", i),
+			URL:           fmt.Sprintf("https://nfug.connpass.com/event/%d/", 100000+i),
+			Place:         "会場",
+			StartedAt:     start,
+			EndedAt:       start.Add(3 * time.Hour),
+			Limit:         30,
+			Accepted:      i % 35,
+			Waiting:       i % 7,
+			Hashtag:       "nfug",
+			Talks:         i % 3,
+			Slots:         2,
+			RegularHour:   19,
+			QuietRatio:    0.5,
+			CapacityRatio: 0.9,
+			WaitlistRatio: 0.2,
+			DoorOpen:      30 * time.Minute,
+			MorningHour:   10,
+		}
+	}
+	return events
+}
+
+// BenchmarkEvaluate checks every rule against the events, as evaluateRules does in a cron run
+func BenchmarkEvaluate(b *testing.B) {
+	now := at(2024, 3, 1, 19, 30)
+	events := benchmarkEvents(50, now)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			e.Stage = Advance("", e, now)
+			for _, rule := range Rules {
+				if rule.InStage(e) && rule.Predicate(e, now) {
+					rule.Due(e, time.Time{}, now)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkRender renders every rule for the events, the worst case of all rules firing at once
+func BenchmarkRender(b *testing.B) {
+	now := at(2024, 3, 1, 19, 30)
+	events := benchmarkEvents(50, now)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			for _, rule := range Rules {
+				if _, err := rule.Render(e); err != nil {
+					b.Fatalf("render %s: %v", rule.Name, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRulesRender(t *testing.T) {
+	e := benchmarkEvents(1, at(2024, 3, 1, 19, 30))[0]
+	for _, rule := range Rules {
+		if _, err := rule.Render(e); err != nil {
+			t.Errorf("render %s: %v", rule.Name, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !appengine
+// +build !appengine
+
+package slackbot
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/robfig/cron/v3"
+	"google.golang.org/appengine"
+)
+
+// Serve runs the bot as its own process, on App Engine runtimes that provide the App Engine APIs
+// (Datastore, urlfetch, logging) to a main package. Outside them the APIs are unavailable.
+func Serve() {
+	handleShutdown()
+	startScheduler()
+	appengine.Main()
+}
+
+// scheduler is the internal scheduler, nil unless started by Serve
+var scheduler *cron.Cron
+
+// startScheduler runs the polling on the SCHEDULE cron expression (e.g. "0 * * * *") by requesting
+// the polling endpoint of this instance, when RUN_SCHEDULER is "true". App Engine runs the polling by cron.yaml.
+// Every instance with the flag polls, so set it on exactly one instance.
+func startScheduler() {
+	spec := os.Getenv("SCHEDULE")
+	if spec == "" || os.Getenv("RUN_SCHEDULER") != "true" {
+		return
+	}
+
+	target := os.Getenv("SCHEDULE_URL")
+	if target == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8080"
+		}
+		target = "http://localhost:" + port + "/"
+	}
+
+	scheduler = cron.New()
+	if _, err := scheduler.AddFunc(spec, func() { runScheduled(target) }); err != nil {
+		log.Printf("config: SCHEDULE: %v", err)
+		return
+	}
+	scheduler.Start()
+}
+
+func runScheduled(target string) {
+	resp, err := http.Get(target)
+	if err != nil {
+		log.Printf("schedule: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("schedule: %s", resp.Status)
+	}
+}
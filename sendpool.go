@@ -0,0 +1,99 @@
+package slackbot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+// sendJob is a notification handed to the pool, with the context of its community
+type sendJob struct {
+	ctx context.Context
+	pendingNotification
+}
+
+// sendPool sends the notifications of a cron run with a bounded number of workers.
+// Jobs that can't be posted before the deadline, including the wait between posts to a channel, are deferred to the next cron run.
+type sendPool struct {
+	jobs     chan sendJob
+	wg       sync.WaitGroup
+	deadline time.Time
+
+	mu       sync.Mutex
+	errors   []string
+	overflow int
+}
+
+func newSendPool(workers int, budget time.Duration) *sendPool {
+	p := &sendPool{
+		jobs:     make(chan sendJob),
+		deadline: time.Now().Add(budget),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *sendPool) work() {
+	defer p.wg.Done()
+	w := &workerWriter{pool: p, header: http.Header{}}
+	for job := range p.jobs {
+		deferred := time.Now().After(p.deadline)
+		if deferred {
+			deferNotification(job.ctx, job.Kind, job.Event, job.Channel, job.Text, job.Blocks)
+		} else {
+			ctx := withSendBudget(job.ctx, p.deadline)
+			deferred = send(ctx, w, job.Kind, job.Event, job.Channel, job.Text, job.Blocks...) == errSendBudget
+		}
+		if deferred {
+			p.mu.Lock()
+			p.overflow++
+			p.mu.Unlock()
+		}
+	}
+}
+
+// submit blocks until a worker is free
+func (p *sendPool) submit(ctx context.Context, n pendingNotification) {
+	p.jobs <- sendJob{ctx: ctx, pendingNotification: n}
+}
+
+// wait lets the workers finish and adds what they ran into to the report
+func (p *sendPool) wait(ctx context.Context, report *runReport) {
+	close(p.jobs)
+	p.wg.Wait()
+
+	report.Errors = append(report.Errors, p.errors...)
+	report.Overflow = p.overflow
+	if p.overflow > 0 {
+		log.Warningf(ctx, "send budget exceeded: %d notifications deferred", p.overflow)
+	}
+}
+
+// workerWriter is the ResponseWriter of the sends of a worker, so that their http.Error calls
+// are collected for the report like runReport does
+type workerWriter struct {
+	pool   *sendPool
+	header http.Header
+}
+
+func (w *workerWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *workerWriter) Write(b []byte) (int, error) {
+	if message := strings.TrimSpace(string(b)); message != "" {
+		w.pool.mu.Lock()
+		w.pool.errors = append(w.pool.errors, message)
+		w.pool.mu.Unlock()
+	}
+	return len(b), nil
+}
+
+func (w *workerWriter) WriteHeader(statusCode int) {}
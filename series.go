@@ -0,0 +1,66 @@
+package slackbot
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+)
+
+// SeriesConfig is the announcement settings of a connpass series
+type SeriesConfig struct {
+	GeneralChannel string `yaml:"general"`
+	ManageChannel  string `yaml:"manage"`
+	Hashtag        string `yaml:"hashtag"`
+	// Twitter is the official account of the series without @, credited in tweets
+	Twitter string `yaml:"twitter"`
+	// MembersChannel is a members-only channel for the streaming URL, DMs to the participants when empty
+	MembersChannel string `yaml:"members"`
+	// Team is the Slack team ID installed via /slack/install, empty for SLACK_BOT_TOKEN
+	Team string `yaml:"team"`
+	// WelcomeFirstTimers greets first-time participants in the start message
+	WelcomeFirstTimers bool `yaml:"welcome_first_timers"`
+	// IntroChannel is where first-timers are invited to introduce themselves
+	IntroChannel string `yaml:"intro"`
+}
+
+var (
+	defaultSeriesConfig = SeriesConfig{
+		GeneralChannel: "#general",
+		ManageChannel:  "#manage",
+		Hashtag:        "nfug",
+	}
+)
+
+func seriesConfigFor(ctx context.Context, event Event) SeriesConfig {
+	if config, ok := currentSettings(ctx).Series[event.SeriesID]; ok {
+		return config
+	}
+	return defaultSeriesConfig
+}
+
+// channel resolves a rule destination to the channel of the series
+func (s SeriesConfig) channel(name string) string {
+	if name == rules.Manage {
+		return s.ManageChannel
+	}
+	return s.GeneralChannel
+}
+
+func hashtagSearchURL(hashtag string) string {
+	return "https://twitter.com/search?q=" + url.QueryEscape("#"+hashtag)
+}
+
+// tweetIntentURL returns a link opening a pre-filled tweet about the event
+// ref: https://developer.twitter.com/en/docs/twitter-for-websites/tweet-button/guides/web-intent
+func tweetIntentURL(title, eventURL, hashtag, account string) string {
+	query := url.Values{
+		"text":     {"『" + title + "』に参加中！"},
+		"url":      {eventURL},
+		"hashtags": {hashtag},
+	}
+	if account != "" {
+		query.Set("via", account)
+	}
+	return "https://twitter.com/intent/tweet?" + query.Encode()
+}
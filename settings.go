@@ -0,0 +1,458 @@
+package slackbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	settingsFile = "settings.yaml"
+	settingsKind = "Settings"
+	settingsName = "current"
+)
+
+var adminToken = os.Getenv("ADMIN_TOKEN")
+
+// Settings are the tunables of the bot, read from settings.yaml or the copy stored in Datastore
+type Settings struct {
+	Series                  map[int]SeriesConfig  `yaml:"series"`
+	RegularHour             int                   `yaml:"regular_hour"`
+	MorningHour             int                   `yaml:"morning_hour"`
+	DoorOpen                string                `yaml:"door_open"`
+	QuietEventRatio         float64               `yaml:"quiet_event_ratio"`
+	CapacityWarningRatio    float64               `yaml:"capacity_warning_ratio"`
+	WaitlistEscalationRatio float64               `yaml:"waitlist_escalation_ratio"`
+	ProgramSlots            int                   `yaml:"program_slots"`
+	QuietHours              []string              `yaml:"quiet_hours"`
+	BlackoutPeriods         []string              `yaml:"blackout_periods"`
+	Templates               map[string]string     `yaml:"templates"`
+	Variants                map[string]string     `yaml:"variants"`
+	Partners                []Partner             `yaml:"partners"`
+	SendInterval            string                `yaml:"send_interval"`
+	BatchPerChannel         bool                  `yaml:"batch_per_channel"`
+	AnnounceGrace           string                `yaml:"announce_grace"`
+	ConnpassErrorThreshold  int                   `yaml:"connpass_error_threshold"`
+	ConnpassErrorWindow     string                `yaml:"connpass_error_window"`
+	RelatedKeywords         []string              `yaml:"related_keywords"`
+	Venues                  map[string]Venue      `yaml:"venues"`
+	Tags                    map[string]TagProfile `yaml:"tags"`
+	// Approval holds notifications mentioning the whole channel or going to ApprovalChannels
+	// until an organizer approves them in #manage, or ApprovalTimeout passes
+	Approval         bool     `yaml:"approval"`
+	ApprovalChannels []string `yaml:"approval_channels"`
+	ApprovalTimeout  string   `yaml:"approval_timeout"`
+	// AlertChannel receives problems of the bot, #manage of the default series when empty
+	AlertChannel string `yaml:"alert_channel"`
+	// CodeOfConduct is the URL of the code of conduct, linked from the event summary
+	CodeOfConduct string `yaml:"code_of_conduct"`
+	// OrganizersGroup is the Slack user group ID mentioned when #manage tasks are left unanswered
+	OrganizersGroup string `yaml:"organizers_group"`
+	// Lead is the Slack user ID of the community lead, told when the mention is left unanswered too
+	Lead string `yaml:"lead"`
+	// ParticipantSummary posts first-timers and repeaters from the connpass participation page to #manage
+	// the day before. Enable it only when the event page tells participants about it.
+	ParticipantSummary bool `yaml:"participant_summary"`
+	// SendWorkers send the posts of a cron run in parallel, each channel paced by ChannelInterval,
+	// and posts that can't start within SendBudget of the run are left to the next one
+	SendWorkers     int    `yaml:"send_workers"`
+	ChannelInterval string `yaml:"channel_interval"`
+	SendBudget      string `yaml:"send_budget"`
+	// NoEventsNudgeDays without an upcoming event start weekly reminders to #manage,
+	// twice a week after NoEventsEscalateDays
+	NoEventsNudgeDays    int `yaml:"no_events_nudge_days"`
+	NoEventsEscalateDays int `yaml:"no_events_escalate_days"`
+	// TicketAlerts are parts of participation type names alerted to #manage when unfilled close to the event
+	TicketAlerts []string `yaml:"ticket_alerts"`
+	// Roles maps Slack user IDs to viewer, organizer or admin, who can get a token for /admin/ by /nfug token
+	Roles map[string]string `yaml:"roles"`
+	// Team is the Slack team ID of the community, installed via /slack/install
+	Team string `yaml:"team"`
+	// Communities are other communities run by this deployment, keyed by their Datastore namespace
+	Communities map[string]interface{} `yaml:"communities"`
+
+	quietHours          []clockRange
+	blackoutPeriods     []dateRange
+	sendInterval        time.Duration
+	channelInterval     time.Duration
+	sendBudget          time.Duration
+	announceGrace       time.Duration
+	doorOpen            time.Duration
+	connpassErrorWindow time.Duration
+	approvalTimeout     time.Duration
+	communities         map[string]*Settings
+	loadedAt            time.Time
+	// hash identifies the YAML applied, shown by /nfug debug
+	hash string
+}
+
+// StoredSettings is the YAML uploaded via /admin/reload
+type StoredSettings struct {
+	YAML      string `datastore:",noindex"`
+	UpdatedAt time.Time
+}
+
+// settings holds the current *Settings, swapped atomically on reload
+var settings atomic.Value
+
+func defaultSettings() *Settings {
+	return &Settings{
+		RegularHour:             19,
+		MorningHour:             10,
+		QuietEventRatio:         0.5,
+		CapacityWarningRatio:    0.9,
+		WaitlistEscalationRatio: 0.2,
+		ProgramSlots:            defaultProgramSlot,
+		SendWorkers:             4,
+		NoEventsNudgeDays:       14,
+		TicketAlerts:            []string{"LT"},
+		NoEventsEscalateDays:    42,
+		channelInterval:         time.Second,
+		sendBudget:              8 * time.Minute,
+		ConnpassErrorThreshold:  3,
+		connpassErrorWindow:     time.Hour,
+		approvalTimeout:         defaultApprovalPeriod,
+		doorOpen:                30 * time.Minute,
+	}
+}
+
+// globalSettings returns the whole settings, whose top level is the default community
+func globalSettings() *Settings {
+	if s, ok := settings.Load().(*Settings); ok {
+		return s
+	}
+	return defaultSettings()
+}
+
+type settingsKey struct{}
+
+// currentSettings returns the settings of the community ctx is processed for
+func currentSettings(ctx context.Context) *Settings {
+	if s, ok := ctx.Value(settingsKey{}).(*Settings); ok {
+		return s
+	}
+	return globalSettings()
+}
+
+// parseSettings reads YAML, fills defaults and validates it together with the communities in it
+func parseSettings(raw []byte) (*Settings, error) {
+	s, err := parseCommunity(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	s.communities = map[string]*Settings{}
+	for name, value := range s.Communities {
+		if name == "" || !namespaceRe.MatchString(name) {
+			return nil, fmt.Errorf("communities: %q is not a valid namespace", name)
+		}
+
+		raw, err := yaml.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		community, err := parseCommunity(raw)
+		if err != nil {
+			return nil, fmt.Errorf("communities: %s: %v", name, err)
+		}
+		if len(community.Series) == 0 {
+			return nil, fmt.Errorf("communities: %s: series is required", name)
+		}
+		if len(community.Communities) > 0 {
+			return nil, fmt.Errorf("communities: %s: communities can't be nested", name)
+		}
+		community.loadedAt = s.loadedAt
+		s.communities[name] = community
+	}
+
+	return s, nil
+}
+
+// parseCommunity reads the settings of one community
+func parseCommunity(raw []byte) (*Settings, error) {
+	s := defaultSettings()
+	if err := yaml.UnmarshalStrict(raw, s); err != nil {
+		return nil, err
+	}
+
+	for id, series := range s.Series {
+		if series.GeneralChannel == "" {
+			series.GeneralChannel = defaultSeriesConfig.GeneralChannel
+		}
+		if series.ManageChannel == "" {
+			series.ManageChannel = defaultSeriesConfig.ManageChannel
+		}
+		if series.Hashtag == "" {
+			series.Hashtag = defaultSeriesConfig.Hashtag
+		}
+		series.Hashtag = strings.TrimPrefix(series.Hashtag, "#")
+		series.Twitter = strings.TrimPrefix(series.Twitter, "@")
+		s.Series[id] = series
+	}
+
+	if s.RegularHour < 0 || s.RegularHour > 23 {
+		return nil, fmt.Errorf("regular_hour %d is out of range", s.RegularHour)
+	}
+	if s.MorningHour < 0 || s.MorningHour > 23 {
+		return nil, fmt.Errorf("morning_hour %d is out of range", s.MorningHour)
+	}
+	if s.QuietEventRatio <= 0 || s.QuietEventRatio > 1 {
+		return nil, fmt.Errorf("quiet_event_ratio %v is out of range", s.QuietEventRatio)
+	}
+	if s.CapacityWarningRatio <= 0 || s.CapacityWarningRatio > 1 {
+		return nil, fmt.Errorf("capacity_warning_ratio %v is out of range", s.CapacityWarningRatio)
+	}
+	if s.WaitlistEscalationRatio < 0 {
+		return nil, fmt.Errorf("waitlist_escalation_ratio %v is negative", s.WaitlistEscalationRatio)
+	}
+	if s.ProgramSlots < 0 {
+		return nil, fmt.Errorf("program_slots %d is negative", s.ProgramSlots)
+	}
+
+	var err error
+	if s.quietHours, err = parseQuietHours(strings.Join(s.QuietHours, ",")); err != nil {
+		return nil, err
+	}
+	if s.blackoutPeriods, err = parseBlackoutPeriods(strings.Join(s.BlackoutPeriods, ",")); err != nil {
+		return nil, err
+	}
+
+	if s.SendInterval != "" {
+		if s.sendInterval, err = time.ParseDuration(s.SendInterval); err != nil || s.sendInterval < 0 {
+			return nil, fmt.Errorf("send_interval %q is invalid", s.SendInterval)
+		}
+	}
+	if s.NoEventsNudgeDays < 0 || s.NoEventsEscalateDays < 0 {
+		return nil, fmt.Errorf("no_events_nudge_days %d and no_events_escalate_days %d must not be negative", s.NoEventsNudgeDays, s.NoEventsEscalateDays)
+	}
+	if s.SendWorkers < 1 {
+		return nil, fmt.Errorf("send_workers %d must be positive", s.SendWorkers)
+	}
+	if s.ChannelInterval != "" {
+		if s.channelInterval, err = time.ParseDuration(s.ChannelInterval); err != nil || s.channelInterval < 0 {
+			return nil, fmt.Errorf("channel_interval %q is invalid", s.ChannelInterval)
+		}
+	}
+	if s.SendBudget != "" {
+		if s.sendBudget, err = time.ParseDuration(s.SendBudget); err != nil || s.sendBudget <= 0 {
+			return nil, fmt.Errorf("send_budget %q is invalid", s.SendBudget)
+		}
+	}
+	if s.AnnounceGrace != "" {
+		if s.announceGrace, err = time.ParseDuration(s.AnnounceGrace); err != nil || s.announceGrace < 0 {
+			return nil, fmt.Errorf("announce_grace %q is invalid", s.AnnounceGrace)
+		}
+	}
+	if s.DoorOpen != "" {
+		if s.doorOpen, err = time.ParseDuration(s.DoorOpen); err != nil || s.doorOpen < 0 {
+			return nil, fmt.Errorf("door_open %q is invalid", s.DoorOpen)
+		}
+	}
+	if s.ConnpassErrorWindow != "" {
+		if s.connpassErrorWindow, err = time.ParseDuration(s.ConnpassErrorWindow); err != nil || s.connpassErrorWindow <= 0 || s.connpassErrorWindow > connpassFailureRetention {
+			return nil, fmt.Errorf("connpass_error_window %q is invalid", s.ConnpassErrorWindow)
+		}
+	}
+	if s.ApprovalTimeout != "" {
+		if s.approvalTimeout, err = time.ParseDuration(s.ApprovalTimeout); err != nil || s.approvalTimeout <= 0 {
+			return nil, fmt.Errorf("approval_timeout %q is invalid", s.ApprovalTimeout)
+		}
+	}
+	if s.ConnpassErrorThreshold < 0 {
+		return nil, fmt.Errorf("connpass_error_threshold %d is negative", s.ConnpassErrorThreshold)
+	}
+
+	if s.CodeOfConduct != "" && !isHTTPSURL(s.CodeOfConduct) {
+		return nil, fmt.Errorf("code_of_conduct %q must be an https URL", s.CodeOfConduct)
+	}
+
+	for _, partner := range s.Partners {
+		if partner.Name == "" {
+			return nil, errors.New("partners: name is required")
+		}
+		if partner.WebhookURL != "" && !isHTTPSURL(partner.WebhookURL) {
+			return nil, fmt.Errorf("partners: %s: webhook_url must be an https URL", partner.Name)
+		}
+	}
+
+	for userID, name := range s.Roles {
+		if _, ok := roleNames[name]; !ok {
+			return nil, fmt.Errorf("roles: %s: unknown role %q", userID, name)
+		}
+	}
+
+	for tag, profile := range s.Tags {
+		for _, name := range append(profile.Skip, profile.Enable...) {
+			if _, ok := rules.Find(name); !ok {
+				return nil, fmt.Errorf("tags: %s: unknown rule %q", tag, name)
+			}
+		}
+	}
+
+	for name, text := range s.Templates {
+		rule, ok := rules.Find(name)
+		if !ok {
+			return nil, fmt.Errorf("templates: unknown rule %q", name)
+		}
+		rule.Template = text
+		if _, err := rule.Render(rules.Event{}); err != nil {
+			return nil, fmt.Errorf("templates: %s: %v", name, err)
+		}
+	}
+
+	for name, text := range s.Variants {
+		rule, ok := rules.Find(name)
+		if !ok {
+			return nil, fmt.Errorf("variants: unknown rule %q", name)
+		}
+		rule.Template = text
+		if _, err := rule.Render(rules.Event{}); err != nil {
+			return nil, fmt.Errorf("variants: %s: %v", name, err)
+		}
+	}
+
+	s.loadedAt = time.Now()
+	s.hash = fmt.Sprintf("%x", sha256.Sum256(raw))[:12]
+	return s, nil
+}
+
+// loadSettingsFile applies settings.yaml deployed alongside the app
+func loadSettingsFile() error {
+	raw, err := ioutil.ReadFile(settingsFile)
+	if err != nil {
+		return err
+	}
+
+	s, err := parseSettings(raw)
+	if err != nil {
+		return err
+	}
+
+	settings.Store(s)
+	return nil
+}
+
+// refreshSettings applies the settings stored in Datastore when they are newer than the current ones
+func refreshSettings(ctx context.Context) {
+	stored, ok := loadStoredSettings(ctx)
+	if !ok || !stored.UpdatedAt.After(globalSettings().loadedAt) {
+		return
+	}
+	if err := applyStoredSettings(stored); err != nil {
+		log.Errorf(ctx, "settings: %v", err)
+	}
+}
+
+func loadStoredSettings(ctx context.Context) (StoredSettings, bool) {
+	key := datastore.NewKey(ctx, settingsKind, settingsName, 0, nil)
+
+	var stored StoredSettings
+	if err := datastore.Get(ctx, key, &stored); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "settings get: %v", err)
+		}
+		return StoredSettings{}, false
+	}
+	return stored, true
+}
+
+func applyStoredSettings(stored StoredSettings) error {
+	s, err := parseSettings([]byte(stored.YAML))
+	if err != nil {
+		return err
+	}
+	settings.Store(s)
+	return nil
+}
+
+// handleReload stores and applies the posted YAML. With an empty body it re-applies the YAML stored earlier,
+// or settings.yaml when none was stored.
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := appengine.NewContext(r)
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		// the stored copy is applied whatever its UpdatedAt, which refreshSettings compares to the load time
+		if stored, ok := loadStoredSettings(ctx); ok {
+			err = applyStoredSettings(stored)
+		} else {
+			err = loadSettingsFile()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "reloaded")
+		return
+	}
+
+	s, err := parseSettings(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := datastore.NewKey(ctx, settingsKind, settingsName, 0, nil)
+	if _, err := datastore.Put(ctx, key, &StoredSettings{YAML: string(raw), UpdatedAt: s.loadedAt}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	settings.Store(s)
+
+	fmt.Fprintln(w, "reloaded")
+}
+
+// seriesIDs is the series_id parameter of the connpass API
+func (s *Settings) seriesIDs() string {
+	if len(s.Series) == 0 {
+		return defaultConnpassSeriesID
+	}
+
+	var ids []int
+	for id := range s.Series {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var parts []string
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+// rule returns the rule with its template overridden by the settings
+func (s *Settings) rule(rule rules.Rule) rules.Rule {
+	if text, ok := s.Templates[rule.Name]; ok {
+		rule.Template = text
+	}
+	return rule
+}
@@ -0,0 +1,95 @@
+package slackbot
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const shortURLKind = "ShortURL"
+
+var shortURLBase = strings.TrimSuffix(os.Getenv("SHORT_URL_BASE"), "/")
+
+// ShortURL is a redirect with a click counter, keyed by its ID
+type ShortURL struct {
+	Target    string
+	Type      string
+	Clicks    int
+	CreatedAt time.Time
+}
+
+// shortURLID is stable per target and notification type, so each type is counted separately
+func shortURLID(target, kind string) string {
+	sum := sha1.Sum([]byte(kind + " " + target))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// shorten returns the short URL of target for the notification type, or target itself when disabled
+func shorten(ctx context.Context, target, kind string) string {
+	if shortURLBase == "" || target == "" {
+		return target
+	}
+
+	// short URLs are shared by all communities, /r/ doesn't know the community
+	ctx = rootContext(ctx)
+	id := shortURLID(target, kind)
+	key := datastore.NewKey(ctx, shortURLKind, id, 0, nil)
+
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		var short ShortURL
+		err := datastore.Get(tc, key, &short)
+		if err == nil {
+			return nil
+		}
+		if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		_, err = datastore.Put(tc, key, &ShortURL{Target: target, Type: kind, CreatedAt: time.Now()})
+		return err
+	}, nil)
+	if err != nil {
+		log.Errorf(ctx, "shorten %s: %v", target, err)
+		return target
+	}
+
+	return shortURLBase + "/r/" + id
+}
+
+// handleRedirect counts the click and redirects to the target
+func handleRedirect(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	id := strings.TrimPrefix(r.URL.Path, "/r/")
+	key := datastore.NewKey(ctx, shortURLKind, id, 0, nil)
+
+	var short ShortURL
+	err := datastore.RunInTransaction(ctx, func(tc context.Context) error {
+		if err := datastore.Get(tc, key, &short); err != nil {
+			return err
+		}
+		short.Clicks++
+		_, err := datastore.Put(tc, key, &short)
+		return err
+	}, nil)
+	if err == datastore.ErrNoSuchEntity {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "redirect %s: %v", id, err)
+		if short.Target == "" {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, short.Target, http.StatusFound)
+}
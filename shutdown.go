@@ -0,0 +1,44 @@
+//go:build !appengine
+// +build !appengine
+
+package slackbot
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Cloud Run allows 10 seconds between SIGTERM and SIGKILL
+// ref: https://cloud.google.com/run/docs/container-contract#instance-shutdown
+const shutdownTimeout = 9 * time.Second
+
+// handleShutdown stops the internal scheduler on SIGTERM, answers new requests with 503, and lets running requests
+// send their batched notifications and audit entries before the process exits. It is installed by Serve.
+func handleShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		log.Printf("shutdown: waiting for running requests")
+		if scheduler != nil {
+			scheduler.Stop()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			drain()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			log.Printf("shutdown: gave up waiting after %s", shutdownTimeout)
+		}
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,157 @@
+package slackbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const slackAPIURL = "https://slack.com/api/"
+
+var (
+	slackBotToken      = os.Getenv("SLACK_BOT_TOKEN")
+	slackSigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
+)
+
+// slackAPIResponse is the common part of Web API responses
+// ref: https://api.slack.com/web#responses
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+// callSlackAPI calls the Slack Web API method with the bot token of the team of ctx
+func callSlackAPI(ctx context.Context, method string, params map[string]interface{}) (slackAPIResponse, error) {
+	token := botToken(ctx)
+	if token == "" {
+		return slackAPIResponse{}, errors.New("SLACK_BOT_TOKEN is not set")
+	}
+
+	redirectToStaging(method, params)
+	buffer, _ := json.Marshal(params)
+	body, err := doSlackRequest(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, slackAPIURL+method, bytes.NewReader(buffer))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+	if err != nil {
+		return slackAPIResponse{}, err
+	}
+
+	var result slackAPIResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return slackAPIResponse{}, err
+	}
+	if !result.OK {
+		return result, &slackAPIError{Method: method, Code: result.Error}
+	}
+
+	return result, nil
+}
+
+// callSlackAPIGet calls a read method of the Slack Web API and decodes the response into result
+func callSlackAPIGet(ctx context.Context, method string, params url.Values, result interface{}) error {
+	token := botToken(ctx)
+	if token == "" {
+		return errors.New("SLACK_BOT_TOKEN is not set")
+	}
+
+	body, err := doSlackRequest(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, slackAPIURL+method+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var status slackAPIResponse
+	if err := json.Unmarshal(body, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return &slackAPIError{Method: method, Code: status.Error}
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// postMessage posts text to a channel, or as a direct message when channel is a user ID.
+// It returns the ts of the posted message.
+// ref: https://api.slack.com/methods/chat.postMessage
+func postMessage(ctx context.Context, channel, text string, blocks ...interface{}) (string, error) {
+	params := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+	}
+	if len(blocks) > 0 {
+		params["blocks"] = blocks
+	}
+
+	result, err := callSlackAPI(ctx, "chat.postMessage", params)
+	return result.TS, err
+}
+
+// updateMessage replaces the message posted at ts
+// ref: https://api.slack.com/methods/chat.update
+func updateMessage(ctx context.Context, channel, ts, text string, blocks ...interface{}) error {
+	params := map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+		"text":    text,
+	}
+	if len(blocks) > 0 {
+		params["blocks"] = blocks
+	}
+
+	_, err := callSlackAPI(ctx, "chat.update", params)
+	return err
+}
+
+// verifySlackRequest checks the request signature and returns the raw body
+// ref: https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackRequest(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	if time.Since(time.Unix(sec, 0)) > 5*time.Minute {
+		return nil, errors.New("request is too old")
+	}
+
+	mac := hmac.New(sha256.New, []byte(slackSigningSecret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if slackSigningSecret == "" || !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Slack-Signature"))) {
+		return nil, errors.New("invalid signature")
+	}
+
+	return body, nil
+}
@@ -2,169 +2,291 @@ package slackbot
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"regexp"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/urlfetch"
+	"google.golang.org/appengine/log"
 )
 
 const (
-	location            = "Asia/Tokyo"
-	connpassURL         = "https://connpass.com/api/v1/event/"
-	slackURL            = "https://nfug.slack.com/"
-	connpassGroupID     = "964,4986" // 964: html5nagoya, 4986: nfug
-	regularHour         = 19
-	textTwoWeeksBefore1 = "2週間前になりました。参加者はそれなりに多いようです。やったね！"
-	textTwoWeeksBefore2 = "2週間前になりました。参加者が少し少ないようです。みんなで宣伝しましょう！"
-	textOneWeekBefore   = "1週間前になりました。次回の会場が決まっていない場合は検討しましょう。"
-	textTwoDaysBefore   = "2日前です。当日参加できないことが分かっている方は、前日までにキャンセルしましょう。"
-	textStart           = "イベントスタートです！\nTwitter のハッシュタグ #nfug (https://twitter.com/search?q=%23nfug) もご活用ください！"
-	textNextDay         = "昨日のイベントお疲れさまでした。次のイベントが立っていなければ用意しましょう！"
+	location    = "Asia/Tokyo"
+	connpassURL = "https://connpass.com/api/v1/event/"
+	// used when settings.yaml has no series; 964: html5nagoya, 4986: nfug
+	defaultConnpassSeriesID = "964,4986"
+	textHeadcount           = "connpass 参加者 %d人 / Slack内で参加表明 %d人\n"
+	textCommandUsage        = "使い方:\n/nfug remindme 1h 1d (イベント前に DM でお知らせ) / /nfug remindme off (解除)\n/nfug remind 2024-03-13 10:00 #manage 内容 (指定日時にリマインダーを送る)\n/nfug poll 3/14 3/21 (日程調整を作成) / /nfug poll close (締め切り)\n/nfug talk タイトル (次回の発表を登録) / /nfug talks (発表の一覧)\n/nfug stats (今年の統計)\n/nfug stream URL (次回の配信 URL を登録) / /nfug link URL タイトル (前回の資料・ブログを追加)\n/nfug token (管理用のトークンを発行)\n/nfug hiatus 2024-12-25 2025-01-05 (休止期間を設定) / /nfug hiatus off (解除)\n/nfug debug (ボットの状態を確認)\n/nfug subscribe (このチャンネルでも告知を受け取る) / /nfug unsubscribe (止める)"
 )
 
 var (
 	slackbotURL = os.Getenv("SLACKBOT_URL")
+	ogImageRe   = regexp.MustCompile(`<meta[^>]+property="og:image"[^>]+content="([^"]+)"`)
 )
 
-// EventResults JSON Data
-// ref: https://connpass.com/about/api/
-type EventResults struct {
-	Events []struct {
-		Title     string    `json:"title"`
-		URL       string    `json:"event_url"`
-		StartedAt time.Time `json:"started_at"`
-		EndedAt   time.Time `json:"ended_at"`
-		Place     string    `json:"place"`
-		Limit     int       `json:"limit"`
-		Accepted  int       `json:"accepted"`
-	} `json:"events"`
-}
-
-func parseEventResults(rawText []byte) EventResults {
-	var eventResults EventResults
+// fallbackEvents returns the last known good events when connpass is unavailable
+func fallbackEvents(ctx context.Context, w http.ResponseWriter, cache ConnpassCache, cause error) []Event {
+	if cache.Body == nil {
+		http.Error(w, cause.Error(), http.StatusInternalServerError)
+		return nil
+	}
 
-	if err := json.Unmarshal(rawText, &eventResults); err != nil {
-		return EventResults{}
+	events, err := parseEvents(cache.Body)
+	if err != nil {
+		http.Error(w, cause.Error(), http.StatusInternalServerError)
+		return nil
 	}
 
-	return eventResults
+	log.Warningf(ctx, "connpass: %v; using cached events fetched at %s (%s stale)", cause, cache.FetchedAt.Format(time.RFC3339), time.Since(cache.FetchedAt).Truncate(time.Minute))
+	return events
 }
 
-func getConnpassEvents(w http.ResponseWriter, r *http.Request) EventResults {
-	// ref: https://cloud.google.com/appengine/docs/standard/go/issue-requests
-	ctx := appengine.NewContext(r)
-	client := urlfetch.Client(ctx)
+// getConnpassEvents fetches events with a conditional request.
+// changed is false when connpass answered 304 or failed and the cached events are returned.
+func getConnpassEvents(ctx context.Context, w http.ResponseWriter) (events []Event, changed bool) {
+	cache := loadConnpassCache(ctx)
 
-	resp, err := client.Get(fmt.Sprintf("%s?count=5&order=2&series_id=%s", connpassURL, connpassGroupID))
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?count=5&order=2&series_id=%s", connpassURL, currentSettings(ctx).seriesIDs()), nil)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return EventResults{}
+		return nil, false
+	}
+	if cache.Body != nil {
+		// ref: https://developer.mozilla.org/docs/Web/HTTP/Conditional_requests
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
+	}
+
+	client, cancel := outboundClient(ctx, connpassTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		recordConnpassFailure(ctx, w, err, time.Now())
+		return fallbackEvents(ctx, w, cache, err), false
 	}
 
 	body, _ := ioutil.ReadAll(resp.Body)
 	resp.Body.Close()
 
-	return parseEventResults(body)
-}
+	if resp.StatusCode == http.StatusNotModified {
+		if !cache.MaintenanceSince.IsZero() {
+			cache.MaintenanceSince = time.Time{}
+			saveConnpassCache(ctx, cache)
+		}
+		events, err := parseEvents(cache.Body)
+		if err != nil {
+			log.Errorf(ctx, "connpass cache: %v", err)
+		}
+		return events, false
+	}
+	if isMaintenance(resp) {
+		return maintenanceEvents(ctx, w, cache, resp, time.Now()), false
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected status %s", resp.Status)
+		recordConnpassFailure(ctx, w, err, time.Now())
+		return fallbackEvents(ctx, w, cache, err), false
+	}
+
+	events, err = parseEvents(body)
+	if err != nil {
+		// the API may have changed; organizers should know even when the cache covers it
+		log.Errorf(ctx, "connpass: %v", err)
+		alert(ctx, w, err.Error(), time.Now())
+		return fallbackEvents(ctx, w, cache, err), false
+	}
 
-func isStartTime(startTime time.Time) bool {
-	now := time.Now()
-	afterOneHour := startTime.Add(time.Hour)
+	saveConnpassCache(ctx, ConnpassCache{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+		FetchedAt:    time.Now(),
+	})
 
-	return startTime.Before(now) && afterOneHour.After(now)
+	return events, true
 }
 
-func isRegularTime() bool {
-	now := time.Now()
-	regularTime := time.Date(now.Year(), now.Month(), now.Day(), regularHour, 0, 0, 0, time.Local)
-	afterOneHour := regularTime.Add(time.Hour)
+// getEventImageURL returns the OGP image of the connpass event page, or "" if not found
+func getEventImageURL(ctx context.Context, eventURL string) string {
+	req, err := http.NewRequest(http.MethodGet, eventURL, nil)
+	if err != nil {
+		return ""
+	}
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	matches := ogImageRe.FindSubmatch(body)
+	if matches == nil {
+		return ""
+	}
 
-	return regularTime.Before(now) && afterOneHour.After(now)
+	return string(matches[1])
 }
 
-func isDaysBefore(target time.Time, days int) bool {
-	now := time.Now()
+func isEnded(endTime time.Time) bool {
+	return endTime.Before(time.Now())
+}
+
+// announcementBlocks builds Block Kit blocks with the event image as an accessory
+// ref: https://api.slack.com/reference/block-kit/blocks#section
+func announcementBlocks(text, imageURL, title string) []interface{} {
+	section := map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			"text": text,
+		},
+	}
+	if imageURL != "" {
+		section["accessory"] = map[string]interface{}{
+			"type":      "image",
+			"image_url": imageURL,
+			"alt_text":  title,
+		}
+	}
 
-	return target.YearDay()-days == now.YearDay()
+	return []interface{}{section}
 }
 
-func isQuietEvent(accepted, limit int) bool {
-	return float64(accepted)/float64(limit) <= 0.5
+// headcount renders connpass and Slack RSVP counts, or "" when Slack RSVP is unavailable
+func headcount(ctx context.Context, event Event) string {
+	rsvp := countRSVP(ctx, event)
+	if rsvp < 0 {
+		return ""
+	}
+	return fmt.Sprintf(textHeadcount, event.Accepted, rsvp)
 }
 
-func slackbot(w http.ResponseWriter, r *http.Request, url, channel, body string) {
-	buffer, _ := json.Marshal(map[string]interface{}{
-		"channnel": channel,
-		"text":     body,
-	})
+// slackbot posts to an incoming webhook
+func slackbot(ctx context.Context, url, channel, body string, blocks ...interface{}) error {
+	if stagingChannel != "" {
+		body = fmt.Sprintf(textStagingPrefix, channel) + body
+		channel = stagingChannel
+	}
+	payload := map[string]interface{}{
+		"text": body,
+	}
+	// the webhook posts to its default channel without one
+	if channel != "" {
+		payload["channel"] = channel
+	}
+	if len(blocks) > 0 {
+		payload["blocks"] = blocks
+	}
+	buffer, _ := json.Marshal(payload)
 
-	// ref: https://cloud.google.com/appengine/docs/standard/go/issue-requests
-	ctx := appengine.NewContext(r)
-	client := urlfetch.Client(ctx)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(buffer))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(buffer))
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 	defer resp.Body.Close()
 
-	fmt.Println(resp)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return nil
 }
 
 func handle(w http.ResponseWriter, r *http.Request) {
-	eventResults := getConnpassEvents(w, r)
-
-	if len(eventResults.Events) == 0 {
-		fmt.Fprintln(w, "no events")
+	ctx := appengine.NewContext(r)
+	report := newRunReport()
+	if len(configErrors) > 0 {
+		report.Errors = append(report.Errors, configErrors...)
+		report.write(w)
 		return
 	}
 
-	for _, event := range eventResults.Events {
-		// notification: 2 weeks ago
-		if isRegularTime() && isDaysBefore(event.StartedAt, 14) {
-			message := ""
-			if isQuietEvent(event.Accepted, event.Limit) {
-				message = textTwoWeeksBefore2
-			} else {
-				message = textTwoWeeksBefore1
-			}
-
-			bottext := fmt.Sprintf("『%s』%s <%s>\n", event.Title, message, event.URL)
-			slackbot(w, r, slackbotURL, "#general", bottext)
+	var throttled int64
+	ctx = withThrottleCount(ctx, &throttled)
+	refreshSettings(ctx)
+	report.pool = newSendPool(globalSettings().SendWorkers, globalSettings().sendBudget)
+	for _, name := range communityNames() {
+		communityCtx, err := withCommunity(ctx, name)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			continue
 		}
+		run(communityCtx, report)
+	}
+	report.pool.wait(ctx, report)
 
-		// notification: 1 week ago
-		if isRegularTime() && isDaysBefore(event.StartedAt, 7) {
-			bottext := fmt.Sprintf("『%s』%s\n", event.Title, textOneWeekBefore)
-			slackbot(w, r, slackbotURL, "#manage", bottext)
-		}
+	report.Throttled = atomic.LoadInt64(&throttled)
+	report.write(w)
+}
 
-		// notification: 2 days ago
-		if isRegularTime() && isDaysBefore(event.StartedAt, 2) {
-			bottext := fmt.Sprintf("『%s』%s <%s>\n", event.Title, textTwoDaysBefore, event.URL)
-			slackbot(w, r, slackbotURL, "#general", bottext)
+// run polls connpass and posts the notifications of the community of ctx
+func run(ctx context.Context, report *runReport) {
+	flushDeferred(ctx, report)
+	deliverReminders(ctx, report, time.Now())
+	releaseApprovals(ctx, report, time.Now())
+
+	events, changed := getConnpassEvents(ctx, report)
+	postYearReview(ctx, report, time.Now())
+	postMonthlyReport(ctx, report, time.Now())
+	postRelatedEvents(ctx, report, time.Now())
+	nagPlans(ctx, report, events, time.Now())
+	nudgeNoEvents(ctx, report, events, time.Now())
+	escalateTasks(ctx, report, time.Now())
+
+	started := time.Now()
+	for _, event := range events {
+		report.Events = append(report.Events, event.URL)
+		migrateEventKeys(ctx, event)
+
+		// nothing changed on connpass: only time-based rules are evaluated
+		var snapshot EventSnapshot
+		if changed {
+			snapshot = updateSnapshot(ctx, event)
+			archiveEvent(ctx, event)
+			updateTickets(ctx, event)
+			syncNotion(ctx, event)
+		} else {
+			snapshot = loadSnapshot(ctx, event)
 		}
 
-		// notification: event start
-		if isStartTime(event.StartedAt) {
-			bottext := fmt.Sprintf("『%s』%s\n", event.Title, textStart)
-			slackbot(w, r, slackbotURL, "#general", bottext)
+		recordAttendance(ctx, event, time.Now())
+		for _, rule := range evaluateRules(ctx, report, event, snapshot, time.Now()) {
+			report.fired(rule, event.URL)
 		}
 
-		// notification: event next day
-		if isRegularTime() && isDaysBefore(event.StartedAt, -1) {
-			bottext := fmt.Sprintf("『%s』%s\n", event.Title, textNextDay)
-			slackbot(w, r, slackbotURL, "#general", bottext)
+		// notification: personal DM reminders
+		if !isEnded(event.EndedAt) {
+			notifySubscribers(ctx, report, event)
 		}
+		shareStream(ctx, report, event, time.Now())
+		syncSummary(ctx, event)
 	}
+	log.Infof(ctx, "evaluated %d events in %s", len(events), time.Since(started))
 
-	fmt.Fprintln(w, slackURL)
+	report.flush(ctx, report)
 }
 
 func init() {
@@ -174,5 +296,28 @@ func init() {
 	}
 	time.Local = loc
 
-	http.HandleFunc("/", handle)
+	configErrors = loadConfig()
+
+	http.HandleFunc("/", tracked(handle))
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/_ah/warmup", handleWarmup)
+	http.HandleFunc("/dashboard", handleDashboard)
+	http.HandleFunc("/next", handleNext)
+	http.HandleFunc("/rules", handleRules)
+	http.HandleFunc("/r/", handleRedirect)
+	http.HandleFunc("/events/", handleEventFile)
+	http.HandleFunc("/admin/reload", handleReload)
+	http.HandleFunc("/admin/preview", handlePreview)
+	http.HandleFunc("/admin/deadletter", tracked(handleDeadLetter))
+	http.HandleFunc("/admin/retract", tracked(handleRetract))
+	http.HandleFunc("/admin/state", handleState)
+	http.HandleFunc("/admin/migrate", handleMigrate)
+	http.HandleFunc("/admin/tags", handleTags)
+	http.HandleFunc("/admin/backfill", handleBackfill)
+	http.HandleFunc("/admin/experiments", handleExperiments)
+	http.HandleFunc("/slack/install", handleInstall)
+	http.HandleFunc("/slack/oauth_redirect", handleOAuthRedirect)
+	http.HandleFunc("/slack/command", tracked(handleCommand))
+	http.HandleFunc("/slack/interactive", tracked(handleInteractive))
+	http.HandleFunc("/slack/events", tracked(handleEvents))
 }
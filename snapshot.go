@@ -0,0 +1,66 @@
+package slackbot
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const snapshotKind = "EventSnapshot"
+
+// EventSnapshot is the last observed state of an event, keyed by event ID
+type EventSnapshot struct {
+	Title                string
+	Limit                int
+	Accepted             int
+	RegistrationOpenedAt time.Time
+	UpdatedAt            time.Time
+}
+
+// updateSnapshot stores the current state of the event and returns it.
+// Registration is regarded as opened when limit becomes available (0 -> N),
+// or when the first participant is accepted while limit is set.
+func updateSnapshot(ctx context.Context, event Event) EventSnapshot {
+	key := eventKey(ctx, snapshotKind, event.URL)
+
+	var prev EventSnapshot
+	found := true
+	if err := datastore.Get(ctx, key, &prev); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "snapshot get %s: %v", event.URL, err)
+		}
+		found = false
+	}
+
+	now := time.Now()
+	snapshot := EventSnapshot{
+		Title:                event.Title,
+		Limit:                event.Limit,
+		Accepted:             event.Accepted,
+		RegistrationOpenedAt: prev.RegistrationOpenedAt,
+		UpdatedAt:            now,
+	}
+	opened := prev.Limit == 0 || prev.Accepted == 0 && event.Accepted > 0
+	if found && event.Limit > 0 && opened && prev.RegistrationOpenedAt.IsZero() {
+		snapshot.RegistrationOpenedAt = now
+	}
+
+	if _, err := datastore.Put(ctx, key, &snapshot); err != nil {
+		log.Errorf(ctx, "snapshot put %s: %v", event.URL, err)
+	}
+
+	return snapshot
+}
+
+func loadSnapshot(ctx context.Context, event Event) EventSnapshot {
+	key := eventKey(ctx, snapshotKind, event.URL)
+
+	var snapshot EventSnapshot
+	if err := datastore.Get(ctx, key, &snapshot); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "snapshot get %s: %v", event.URL, err)
+	}
+
+	return snapshot
+}
@@ -0,0 +1,42 @@
+package slackbot
+
+import (
+	"fmt"
+	"os"
+)
+
+const textStagingPrefix = "[staging → %s] "
+
+// stagingChannel receives every message in staging deployments
+var stagingChannel = os.Getenv("STAGING_CHANNEL")
+
+// redirectToStaging rewrites the params of a posting method to the staging channel,
+// prefixing the message with the channel it was meant for
+func redirectToStaging(method string, params map[string]interface{}) {
+	if stagingChannel == "" {
+		return
+	}
+	switch method {
+	case "chat.postMessage", "chat.scheduleMessage", "chat.postEphemeral":
+	default:
+		return
+	}
+
+	target, _ := params["channel"].(string)
+	prefix := fmt.Sprintf(textStagingPrefix, target)
+	params["channel"] = stagingChannel
+	if text, ok := params["text"].(string); ok {
+		params["text"] = prefix + text
+	}
+	// threads only exist in the original channel
+	delete(params, "thread_ts")
+
+	if blocks, ok := params["blocks"].([]interface{}); ok {
+		params["blocks"] = append([]interface{}{
+			map[string]interface{}{
+				"type":     "context",
+				"elements": []interface{}{map[string]interface{}{"type": "mrkdwn", "text": prefix}},
+			},
+		}, blocks...)
+	}
+}
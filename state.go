@@ -0,0 +1,166 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"google.golang.org/appengine"
+)
+
+// stateKinds are the persisted kinds moved by /admin/state, with a constructor of their entity.
+// ConnpassCache is left out as the next cron run fills it again.
+var stateKinds = map[string]func() interface{}{
+	sentKind:         func() interface{} { return &SentNotification{} },
+	snapshotKind:     func() interface{} { return &EventSnapshot{} },
+	announcementKind: func() interface{} { return &Announcement{} },
+	archiveKind:      func() interface{} { return &ArchivedEvent{} },
+	attendanceKind:   func() interface{} { return &AttendanceSnapshot{} },
+	auditKind:        func() interface{} { return &AuditEntry{} },
+	deadLetterKind:   func() interface{} { return &DeadLetter{} },
+	deferredKind:     func() interface{} { return &DeferredNotification{} },
+	experimentKind:   func() interface{} { return &TemplateExperiment{} },
+	hiatusKind:       func() interface{} { return &Hiatus{} },
+	idleKind:         func() interface{} { return &IdlePeriod{} },
+	installationKind: func() interface{} { return &Installation{} },
+	organizerKind:    func() interface{} { return &Organizer{} },
+	approvalKind:     func() interface{} { return &PendingApproval{} },
+	channelSubKind:   func() interface{} { return &ChannelSubscription{} },
+	photoThreadKind:  func() interface{} { return &PhotoThread{} },
+	planKind:         func() interface{} { return &EventPlan{} },
+	participantsKind: func() interface{} { return &EventParticipants{} },
+	pollKind:         func() interface{} { return &Poll{} },
+	settingsKind:     func() interface{} { return &StoredSettings{} },
+	shortURLKind:     func() interface{} { return &ShortURL{} },
+	streamKind:       func() interface{} { return &StreamingURL{} },
+	reminderKind:     func() interface{} { return &AdHocReminder{} },
+	subscriptionKind: func() interface{} { return &ReminderSubscription{} },
+	summaryKind:      func() interface{} { return &EventSummary{} },
+	lifecycleKind:    func() interface{} { return &EventLifecycle{} },
+	linkKind:         func() interface{} { return &EventLink{} },
+	memberCountKind:  func() interface{} { return &MemberCount{} },
+	tagsKind:         func() interface{} { return &EventTags{} },
+	talkKind:         func() interface{} { return &TalkProposal{} },
+	ticketsKind:      func() interface{} { return &TicketBreakdown{} },
+	taskKind:         func() interface{} { return &ManageTask{} },
+}
+
+// secretKinds hold bot tokens, so /admin/state neither exports nor imports them.
+// /admin/migrate still copies them, as it stays within the deployment.
+var secretKinds = map[string]bool{
+	installationKind: true,
+}
+
+// stateRecord is an entity with its key, either a name or a numeric ID
+type stateRecord struct {
+	Name   string          `json:"name,omitempty"`
+	ID     int64           `json:"id,omitempty"`
+	Entity json.RawMessage `json:"entity"`
+}
+
+// stateDump is the whole state, keyed by Datastore namespace and kind
+type stateDump map[string]map[string][]stateRecord
+
+// exportState reads all kinds in the namespaces of the communities
+func exportState(ctx context.Context) (stateDump, error) {
+	dump := stateDump{}
+	for _, name := range communityNames() {
+		kinds := map[string][]stateRecord{}
+		for kind, newEntity := range stateKinds {
+			if secretKinds[kind] {
+				continue
+			}
+			entities, err := datastoreStore{}.load(ctx, name, kind, newEntity)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %v", name, kind, err)
+			}
+
+			records := []stateRecord{}
+			for _, e := range entities {
+				raw, err := json.Marshal(e.Entity)
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, stateRecord{Name: e.Name, ID: e.ID, Entity: raw})
+			}
+			kinds[kind] = records
+		}
+		dump[name] = kinds
+	}
+
+	return dump, nil
+}
+
+// importState writes the dump, overwriting entities with the same keys. It returns the number of entities written.
+func importState(ctx context.Context, dump stateDump) (int, error) {
+	var names []string
+	for name := range dump {
+		if name != "" && !namespaceRe.MatchString(name) {
+			return 0, fmt.Errorf("%q is not a valid namespace", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	count := 0
+	for _, name := range names {
+		for kind, records := range dump[name] {
+			newEntity, ok := stateKinds[kind]
+			if !ok || secretKinds[kind] {
+				return count, fmt.Errorf("%s: unknown kind %q", name, kind)
+			}
+
+			var entities []storedEntity
+			for _, record := range records {
+				entity := newEntity()
+				if err := json.Unmarshal(record.Entity, entity); err != nil {
+					return count, fmt.Errorf("%s/%s: %v", name, kind, err)
+				}
+				entities = append(entities, storedEntity{Name: record.Name, ID: record.ID, Entity: entity})
+			}
+			if err := (datastoreStore{}).save(ctx, name, kind, entities); err != nil {
+				return count, fmt.Errorf("%s/%s: %v", name, kind, err)
+			}
+			count += len(entities)
+		}
+	}
+
+	return count, nil
+}
+
+// handleState exports the persisted state as JSON with GET /admin/state,
+// and imports such an export into this deployment with POST /admin/state
+func handleState(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ctx := appengine.NewContext(r)
+
+	switch r.Method {
+	case http.MethodGet:
+		dump, err := exportState(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dump)
+	case http.MethodPost:
+		var dump stateDump
+		if err := json.NewDecoder(r.Body).Decode(&dump); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		count, err := importState(ctx, dump)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("imported %d entities before: %v", count, err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "imported %d entities\n", count)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
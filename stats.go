@@ -0,0 +1,77 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	textStats          = "%d年のイベント: %d回 / 平均参加者 %.1f人 / 平均充足率 %.0f%%"
+	textStatsNextEvent = "次回: <%s|%s> (%s @%s)"
+	textStatsNoEvent   = "次回のイベントはまだありません。"
+)
+
+// seriesStats summarizes the events of the year so far
+type seriesStats struct {
+	Year        int
+	Events      int
+	AvgAccepted float64
+	AvgFillRate float64
+}
+
+func buildSeriesStats(ctx context.Context, now time.Time) seriesStats {
+	from := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+
+	stats := seriesStats{Year: now.Year()}
+	total, rated := 0, 0
+	for _, event := range archivedEvents(ctx, from, now) {
+		stats.Events++
+		total += event.Accepted
+		if event.Limit > 0 {
+			stats.AvgFillRate += event.fillRate()
+			rated++
+		}
+	}
+	if stats.Events > 0 {
+		stats.AvgAccepted = float64(total) / float64(stats.Events)
+	}
+	if rated > 0 {
+		stats.AvgFillRate /= float64(rated)
+	}
+
+	return stats
+}
+
+// commandStats handles "/nfug stats"
+func commandStats(ctx context.Context) (string, []interface{}) {
+	stats := buildSeriesStats(ctx, time.Now())
+	text := fmt.Sprintf(textStats, stats.Year, stats.Events, stats.AvgAccepted, stats.AvgFillRate*100)
+
+	next := textStatsNoEvent
+	if event, ok := nextEvent(ctx); ok {
+		next = fmt.Sprintf(textStatsNextEvent, event.URL, event.Title, event.StartedAt.Format("2006/01/02 15:04"), event.Place)
+	}
+
+	// ref: https://api.slack.com/reference/block-kit/blocks#section
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": fmt.Sprintf("%d年の統計", stats.Year)},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"fields": []interface{}{
+				map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*開催回数*\n%d回", stats.Events)},
+				map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*平均参加者*\n%.1f人", stats.AvgAccepted)},
+				map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("*平均充足率*\n%.0f%%", stats.AvgFillRate*100)},
+			},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": next},
+		},
+	}
+
+	return text + "\n" + next, blocks
+}
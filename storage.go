@@ -0,0 +1,208 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+)
+
+// firestoreProject is the project with a Firestore database in native mode, the target of /admin/migrate.
+// A project has either Datastore mode or native mode, so it is a different project than the app.
+var firestoreProject = os.Getenv("FIRESTORE_PROJECT")
+
+// storedEntity is an entity with its key, either a name or a numeric ID
+type storedEntity struct {
+	Name   string
+	ID     int64
+	Entity interface{}
+}
+
+// stateStore is a backend holding the bot state, so that the state can move off the legacy appengine packages
+type stateStore interface {
+	// load reads all entities of the kind in the namespace
+	load(ctx context.Context, namespace, kind string, newEntity func() interface{}) ([]storedEntity, error)
+	// save writes the entities, overwriting those with the same keys
+	save(ctx context.Context, namespace, kind string, entities []storedEntity) error
+}
+
+// datastoreStore is App Engine Datastore, in Datastore mode
+type datastoreStore struct{}
+
+func (datastoreStore) load(ctx context.Context, namespace, kind string, newEntity func() interface{}) ([]storedEntity, error) {
+	nsCtx, err := appengine.Namespace(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []storedEntity
+	t := datastore.NewQuery(kind).Run(nsCtx)
+	for {
+		entity := newEntity()
+		key, err := t.Next(entity)
+		if err == datastore.Done {
+			return entities, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, storedEntity{Name: key.StringID(), ID: key.IntID(), Entity: entity})
+	}
+}
+
+func (datastoreStore) save(ctx context.Context, namespace, kind string, entities []storedEntity) error {
+	nsCtx, err := appengine.Namespace(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		key := datastore.NewKey(nsCtx, kind, e.Name, e.ID, nil)
+		if key.Incomplete() {
+			key = datastore.NewIncompleteKey(nsCtx, kind, nil)
+		}
+		if _, err := datastore.Put(nsCtx, key, e.Entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firestoreStore is Firestore in native mode. Kinds of the default namespace are top-level collections,
+// and those of other namespaces are below namespaces/{namespace}.
+type firestoreStore struct {
+	client *firestore.Client
+}
+
+func newFirestoreStore(ctx context.Context) (*firestoreStore, error) {
+	if firestoreProject == "" {
+		return nil, fmt.Errorf("FIRESTORE_PROJECT is not set")
+	}
+	client, err := firestore.NewClient(ctx, firestoreProject)
+	if err != nil {
+		return nil, err
+	}
+	return &firestoreStore{client: client}, nil
+}
+
+func (s *firestoreStore) collection(namespace, kind string) *firestore.CollectionRef {
+	if namespace == "" {
+		return s.client.Collection(kind)
+	}
+	return s.client.Collection("namespaces").Doc(namespace).Collection(kind)
+}
+
+// firestoreDocID maps a Datastore key to a document ID. Names are escaped as they may be URLs with slashes,
+// and numeric IDs are marked with # that escaped names never start with.
+func firestoreDocID(e storedEntity) string {
+	if e.Name != "" {
+		return url.PathEscape(e.Name)
+	}
+	return "#" + strconv.FormatInt(e.ID, 10)
+}
+
+func (s *firestoreStore) load(ctx context.Context, namespace, kind string, newEntity func() interface{}) ([]storedEntity, error) {
+	var entities []storedEntity
+	iter := s.collection(namespace, kind).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return entities, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := storedEntity{Entity: newEntity()}
+		if err := doc.DataTo(e.Entity); err != nil {
+			return nil, fmt.Errorf("%s: %v", doc.Ref.ID, err)
+		}
+		if strings.HasPrefix(doc.Ref.ID, "#") {
+			if e.ID, err = strconv.ParseInt(doc.Ref.ID[1:], 10, 64); err != nil {
+				return nil, fmt.Errorf("%s: %v", doc.Ref.ID, err)
+			}
+		} else if e.Name, err = url.PathUnescape(doc.Ref.ID); err != nil {
+			return nil, fmt.Errorf("%s: %v", doc.Ref.ID, err)
+		}
+		entities = append(entities, e)
+	}
+}
+
+func (s *firestoreStore) save(ctx context.Context, namespace, kind string, entities []storedEntity) error {
+	collection := s.collection(namespace, kind)
+	for _, e := range entities {
+		doc := collection.NewDoc()
+		if e.Name != "" || e.ID != 0 {
+			doc = collection.Doc(firestoreDocID(e))
+		}
+		if _, err := doc.Set(ctx, e.Entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateState copies all kinds of all communities from one store to the other and returns the number of entities copied
+func migrateState(ctx context.Context, from, to stateStore) (int, error) {
+	count := 0
+	for _, name := range communityNames() {
+		for kind, newEntity := range stateKinds {
+			entities, err := from.load(ctx, name, kind, newEntity)
+			if err != nil {
+				return count, fmt.Errorf("%s/%s: %v", name, kind, err)
+			}
+			if err := to.save(ctx, name, kind, entities); err != nil {
+				return count, fmt.Errorf("%s/%s: %v", name, kind, err)
+			}
+			count += len(entities)
+		}
+	}
+	return count, nil
+}
+
+// handleMigrate copies the bot state from Datastore to Firestore with POST /admin/migrate,
+// or back with POST /admin/migrate?to=datastore. Entities with the same keys are overwritten, so it can be run again.
+func handleMigrate(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := appengine.NewContext(r)
+	fs, err := newFirestoreStore(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer fs.client.Close()
+
+	var from, to stateStore = datastoreStore{}, fs
+	switch r.FormValue("to") {
+	case "", "firestore":
+	case "datastore":
+		from, to = fs, datastoreStore{}
+	default:
+		http.Error(w, "to must be firestore or datastore", http.StatusBadRequest)
+		return
+	}
+
+	count, err := migrateState(ctx, from, to)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("migrated %d entities before: %v", count, err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "migrated %d entities\n", count)
+}
@@ -0,0 +1,123 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	streamKind       = "StreamingURL"
+	notifyStream     = "stream"
+	textStream       = "『%s』が始まりました！配信はこちらから視聴できます (参加者限定のため共有はご遠慮ください)\n%s"
+	textStreamNoRole = "配信 URL を扱う権限がありません。settings.yaml の roles で organizer 以上にしてもらってください。"
+)
+
+// StreamingURL is the Zoom or YouTube Live link of an event, keyed by event ID.
+// It is kept in Datastore so that it needn't be written on the public connpass page.
+type StreamingURL struct {
+	EventURL  string
+	URL       string
+	UserID    string
+	UpdatedAt time.Time
+}
+
+func loadStreamingURL(ctx context.Context, eventURL string) (StreamingURL, bool) {
+	key := eventKey(ctx, streamKind, eventURL)
+
+	var stream StreamingURL
+	if err := datastore.Get(ctx, key, &stream); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "stream get %s: %v", eventURL, err)
+		}
+		return StreamingURL{}, false
+	}
+
+	return stream, true
+}
+
+// commandStream handles "/nfug stream <URL>" for the next event, and "/nfug stream" to show it.
+// Only organizers can set or see it, as it is sent to the participants, and once an organizer claimed the event only they can change it.
+func commandStream(ctx context.Context, userID string, args []string) string {
+	if userRole(userID) < roleOrganizer {
+		return textStreamNoRole
+	}
+
+	event, ok := nextEvent(ctx)
+	if !ok {
+		return "次のイベントが見つかりませんでした。"
+	}
+
+	if len(args) == 0 {
+		stream, ok := loadStreamingURL(ctx, event.URL)
+		if !ok {
+			return fmt.Sprintf("『%s』の配信 URL はまだ登録されていません。", event.Title)
+		}
+		return fmt.Sprintf("『%s』の配信 URL: %s (<@%s> が登録)", event.Title, stream.URL, stream.UserID)
+	}
+
+	if organizer, ok := loadOrganizer(ctx, event.URL); ok && organizer.UserID != userID {
+		return fmt.Sprintf("『%s』の配信 URL は担当の <@%s> さんが登録できます。", event.Title, organizer.UserID)
+	}
+
+	// Slack wraps links in <...>
+	raw := strings.Trim(args[0], "<>")
+	if !isHTTPSURL(raw) {
+		return fmt.Sprintf("「%s」は https の URL ではありません。", args[0])
+	}
+
+	stream := StreamingURL{
+		EventURL:  event.URL,
+		URL:       raw,
+		UserID:    userID,
+		UpdatedAt: time.Now(),
+	}
+	key := eventKey(ctx, streamKind, event.URL)
+	if _, err := datastore.Put(ctx, key, &stream); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("『%s』の配信 URL を登録しました。開始時刻に参加者へお知らせします。", event.Title)
+}
+
+// shareStream sends the streaming URL once the event starts, to the members channel of the series
+// or, when there is none, by DM to the users who reacted with ✋ to the announcement
+func shareStream(ctx context.Context, w http.ResponseWriter, event Event, now time.Time) {
+	if !rules.IsStarted(event.StartedAt, now) || isEnded(event.EndedAt) {
+		return
+	}
+	if !loadSent(ctx, notifyStream, event.URL).LastSentAt.IsZero() {
+		return
+	}
+
+	stream, ok := loadStreamingURL(ctx, event.URL)
+	if !ok {
+		return
+	}
+	// claimed once there is a URL to share, so that an overlapping cron run doesn't share it again
+	if !claimSlot(ctx, notifyStream, event.URL, now) {
+		return
+	}
+
+	text := fmt.Sprintf(textStream, event.Title, stream.URL)
+	if channel := seriesConfigFor(ctx, event).MembersChannel; channel != "" {
+		notify(ctx, w, notifyStream, event, channel, text)
+	} else {
+		users, ok := rsvpUsers(ctx, event)
+		if !ok {
+			log.Warningf(ctx, "stream %s: no members channel nor announcement to find participants", event.URL)
+			return
+		}
+		for _, user := range users {
+			notify(ctx, w, notifyStream, event, user, text)
+		}
+	}
+
+	markSent(ctx, notifyStream, event.URL, now)
+}
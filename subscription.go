@@ -0,0 +1,112 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	subscriptionKind   = "ReminderSubscription"
+	notifySubscription = "subscription"
+)
+
+// ReminderSubscription is a personal DM reminder setting, keyed by Slack user ID
+type ReminderSubscription struct {
+	UserID        string
+	OffsetMinutes []int64
+}
+
+// parseOffset parses "30m", "1h" or "2d"
+func parseOffset(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+// commandRemindMe handles "/nfug remindme 1h 1d" and "/nfug remindme off"
+func commandRemindMe(ctx context.Context, userID string, args []string) string {
+	key := datastore.NewKey(ctx, subscriptionKind, userID, 0, nil)
+
+	if len(args) == 0 {
+		return textCommandUsage
+	}
+
+	if args[0] == "off" {
+		if err := datastore.Delete(ctx, key); err != nil && err != datastore.ErrNoSuchEntity {
+			return err.Error()
+		}
+		return "リマインダーを解除しました。"
+	}
+
+	subscription := ReminderSubscription{UserID: userID}
+	for _, arg := range args {
+		offset, err := parseOffset(arg)
+		if err != nil || offset <= 0 {
+			return fmt.Sprintf("「%s」は解釈できませんでした。30m, 1h, 2d のように指定してください。", arg)
+		}
+		subscription.OffsetMinutes = append(subscription.OffsetMinutes, int64(offset/time.Minute))
+	}
+
+	if _, err := datastore.Put(ctx, key, &subscription); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("各イベントの %s 前に DM でお知らせします。", strings.Join(args, ", "))
+}
+
+// isRemindDue reports whether the reminder offset has passed and the event hasn't started yet,
+// so a late or skipped cron run still catches up
+func isRemindDue(startTime time.Time, offset time.Duration, now time.Time) bool {
+	return !startTime.Add(-offset).After(now) && now.Before(startTime)
+}
+
+// subscriptionRule is the name the DM for the user and offset is recorded with in SentNotification
+func subscriptionRule(userID string, minutes int64) string {
+	return fmt.Sprintf("%s %s %d", notifySubscription, userID, minutes)
+}
+
+// notifySubscribers sends personal DM reminders for the event, each once per user, offset and event
+func notifySubscribers(ctx context.Context, w http.ResponseWriter, event Event) {
+	now := time.Now()
+	if inHiatus(ctx, now) {
+		return
+	}
+
+	var subscriptions []ReminderSubscription
+	if _, err := datastore.NewQuery(subscriptionKind).GetAll(ctx, &subscriptions); err != nil {
+		log.Errorf(ctx, "subscription query: %v", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		for _, minutes := range subscription.OffsetMinutes {
+			offset := time.Duration(minutes) * time.Minute
+			if !isRemindDue(event.StartedAt, offset, now) {
+				continue
+			}
+			rule := subscriptionRule(subscription.UserID, minutes)
+			if !loadSent(ctx, rule, event.URL).LastSentAt.IsZero() || !claimSlot(ctx, rule, event.URL, now) {
+				continue
+			}
+
+			loc := userLocation(ctx, subscription.UserID)
+			text := fmt.Sprintf("【%s】『%s』%s 開始です <%s>", rules.TimeToEventIn(event.StartedAt, event.EndedAt, now, loc), event.Title, formatTimeIn(event.StartedAt, "1/2 15:04", loc), event.URL)
+			notify(ctx, w, notifySubscription, event, subscription.UserID, text)
+			markSent(ctx, rule, event.URL, now)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	summaryKind   = "EventSummary"
+	linkKind      = "EventLink"
+	notifySummary = "summary"
+	// summaries are kept up to date for a while after the event to collect slides and blogs
+	summaryRetention = 30 * 24 * time.Hour
+)
+
+// EventSummary is the pinned long-form post of an event, keyed by event ID
+type EventSummary struct {
+	ChannelID string
+	TS        string
+	Text      string `datastore:",noindex"`
+	// Unpinned is set once the event is archived, the summary is pinned from when it is posted until then
+	Unpinned bool
+}
+
+// EventLink is a slide or blog shared after an event
+type EventLink struct {
+	EventURL  string
+	URL       string
+	Title     string
+	UserID    string
+	CreatedAt time.Time
+}
+
+func eventLinks(ctx context.Context, eventURL string) []EventLink {
+	var links []EventLink
+	if _, err := datastore.NewQuery(linkKind).Filter("EventURL =", eventURL).GetAll(ctx, &links); err != nil {
+		log.Errorf(ctx, "link query %s: %v", eventURL, err)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.Before(links[j].CreatedAt) })
+
+	return links
+}
+
+// latestEvent returns the event started most recently
+func latestEvent(ctx context.Context) (Event, bool) {
+	events, err := parseEvents(loadConnpassCache(ctx).Body)
+	if err != nil {
+		return Event{}, false
+	}
+
+	var latest Event
+	found := false
+	now := time.Now()
+	for _, event := range events {
+		if event.StartedAt.After(now) {
+			continue
+		}
+		if !found || event.StartedAt.After(latest.StartedAt) {
+			latest, found = event, true
+		}
+	}
+
+	return latest, found
+}
+
+// commandLink handles "/nfug link <URL> [title]" for the latest event
+func commandLink(ctx context.Context, userID string, args []string) string {
+	if len(args) == 0 {
+		return textCommandUsage
+	}
+
+	event, ok := latestEvent(ctx)
+	if !ok {
+		return "開催済みのイベントが見つかりませんでした。"
+	}
+
+	raw := strings.Trim(args[0], "<>")
+	if !isHTTPSURL(raw) {
+		return fmt.Sprintf("「%s」は https の URL ではありません。", args[0])
+	}
+
+	link := EventLink{
+		EventURL:  event.URL,
+		URL:       raw,
+		Title:     strings.Join(args[1:], " "),
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, linkKind, nil), &link); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("『%s』の資料・ブログに追加しました。", event.Title)
+}
+
+// renderSummary renders the full details of the event
+func renderSummary(ctx context.Context, event Event) string {
+	series := seriesConfigFor(ctx, event)
+
+	text := fmt.Sprintf("*%s*\n<%s>\n日時: %s - %s\n", event.Title, event.URL, event.StartedAt.Format("2006/01/02 15:04"), event.EndedAt.Format("15:04"))
+	if event.Online {
+		text += "会場: オンライン\n"
+	} else {
+		text += fmt.Sprintf("会場: %s\n住所: %s\n地図: %s\n", event.Place, event.Address, mapURL(event))
+		if venue, ok := venueFor(ctx, event); ok {
+			text += venue.notes()
+		}
+	}
+	text += fmt.Sprintf("ハッシュタグ: #%s\n", series.Hashtag)
+	if coc := currentSettings(ctx).CodeOfConduct; coc != "" {
+		text += fmt.Sprintf("行動規範: <%s>\n", coc)
+	}
+
+	if proposals := talkProposals(ctx, event.URL); len(proposals) > 0 {
+		text += "\n*プログラム*\n" + formatLineup(proposals) + "\n"
+	}
+
+	if links := eventLinks(ctx, event.URL); len(links) > 0 {
+		var lines []string
+		for _, link := range links {
+			title := link.Title
+			if title == "" {
+				title = link.URL
+			}
+			lines = append(lines, fmt.Sprintf("• <%s|%s> (<@%s>)", link.URL, title, link.UserID))
+		}
+		text += "\n*資料・ブログ*\n" + strings.Join(lines, "\n") + "\n"
+	}
+
+	return text
+}
+
+// syncSummary posts and pins the summary of an announced event, then keeps it up to date as the data changes.
+// It is unpinned once the event is archived, like the announcement after the next-day message.
+// ref: https://api.slack.com/methods/chat.update
+func syncSummary(ctx context.Context, event Event) {
+	if botToken(ctx) == "" || time.Since(event.EndedAt) > summaryRetention {
+		return
+	}
+
+	key := eventKey(ctx, summaryKind, event.URL)
+	var summary EventSummary
+	if err := datastore.Get(ctx, key, &summary); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "summary get %s: %v", event.URL, err)
+		return
+	}
+
+	if summary.TS != "" && !summary.Unpinned && loadLifecycle(ctx, event.URL).Stage == string(rules.Archived) {
+		// ref: https://api.slack.com/methods/pins.remove
+		if _, err := callSlackAPI(ctx, "pins.remove", map[string]interface{}{
+			"channel":   summary.ChannelID,
+			"timestamp": summary.TS,
+		}); err != nil {
+			log.Errorf(ctx, "summary unpin %s: %v", event.URL, err)
+		} else {
+			summary.Unpinned = true
+			if _, err := datastore.Put(ctx, key, &summary); err != nil {
+				log.Errorf(ctx, "summary put %s: %v", event.URL, err)
+			}
+		}
+	}
+	if inHiatus(ctx, time.Now()) {
+		return
+	}
+
+	text := renderSummary(ctx, event)
+	if text == summary.Text {
+		return
+	}
+
+	if summary.TS == "" {
+		// the summary follows the announcement into its channel
+		announcement, ok := loadAnnouncement(ctx, event)
+		if !ok || isEnded(event.EndedAt) {
+			return
+		}
+		result, err := sendSlack(ctx, notifySummary, announcement.ChannelID, text)
+		if err != nil {
+			log.Errorf(ctx, "summary post %s: %v", event.URL, err)
+			return
+		}
+		summary.ChannelID, summary.TS = result.Channel, result.TS
+
+		// ref: https://api.slack.com/methods/pins.add
+		if _, err := callSlackAPI(ctx, "pins.add", map[string]interface{}{
+			"channel":   summary.ChannelID,
+			"timestamp": summary.TS,
+		}); err != nil {
+			log.Errorf(ctx, "summary pin %s: %v", event.URL, err)
+		}
+	} else if err := updateMessage(ctx, summary.ChannelID, summary.TS, text); err != nil {
+		log.Errorf(ctx, "summary update %s: %v", event.URL, err)
+		return
+	}
+
+	summary.Text = text
+	if _, err := datastore.Put(ctx, key, &summary); err != nil {
+		log.Errorf(ctx, "summary put %s: %v", event.URL, err)
+	}
+}
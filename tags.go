@@ -0,0 +1,140 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const tagsKind = "EventTags"
+
+// TagProfile is how notifications behave for events with the tag, keyed by tag name in settings.yaml
+type TagProfile struct {
+	// Keywords in the title tag the event automatically
+	Keywords []string `yaml:"keywords"`
+	// Skip are rules not fired for the tagged events
+	Skip []string `yaml:"skip"`
+	// Enable are opt-in rules fired for the tagged events
+	Enable []string `yaml:"enable"`
+	// Payment describes the fee or donation collected at the door, e.g. "会場費 500円 (現金・PayPay)"
+	Payment string `yaml:"payment"`
+}
+
+// EventTags are the tags set by /admin/tags, keyed by event ID
+type EventTags struct {
+	Tags      []string
+	UpdatedAt time.Time
+}
+
+// eventTags returns the tags set for the event and those matched by the keywords of the profiles
+func eventTags(ctx context.Context, event Event) []string {
+	tags := map[string]bool{}
+
+	var stored EventTags
+	key := eventKey(ctx, tagsKind, event.URL)
+	if err := datastore.Get(ctx, key, &stored); err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "tags get %s: %v", event.URL, err)
+	}
+	for _, tag := range stored.Tags {
+		tags[tag] = true
+	}
+
+	for tag, profile := range currentSettings(ctx).Tags {
+		for _, keyword := range profile.Keywords {
+			if keyword != "" && strings.Contains(event.Title, keyword) {
+				tags[tag] = true
+			}
+		}
+	}
+
+	var sorted []string
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// tagsPayment returns the payment details of the first profile of the tags that has them
+func tagsPayment(ctx context.Context, tags []string) string {
+	for _, tag := range tags {
+		if payment := currentSettings(ctx).Tags[tag].Payment; payment != "" {
+			return payment
+		}
+	}
+	return ""
+}
+
+// tagsAllow reports whether the rule may fire for an event with the tags:
+// none of the profiles skips it, and opt-in rules are enabled by one of them
+func tagsAllow(ctx context.Context, tags []string, rule rules.Rule) bool {
+	enabled := false
+	for _, tag := range tags {
+		profile := currentSettings(ctx).Tags[tag]
+		for _, name := range profile.Skip {
+			if name == rule.Name {
+				return false
+			}
+		}
+		for _, name := range profile.Enable {
+			enabled = enabled || name == rule.Name
+		}
+	}
+	return !rule.OptIn || enabled
+}
+
+// handleTags sets the tags of an event with POST /admin/tags?event={event URL}&tags=party,seminar,
+// an empty tags clearing them. Keyword matches of settings.yaml apply in addition.
+func handleTags(w http.ResponseWriter, r *http.Request) {
+	if !hasRole(r, roleOrganizer) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, err := withCommunity(appengine.NewContext(r), r.FormValue("community"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	eventURL := r.FormValue("event")
+	if eventURL == "" {
+		http.Error(w, "event is required", http.StatusBadRequest)
+		return
+	}
+
+	// past events are tagged by URL, the event ID only works for the fetched ones
+	if event, ok := findEventByURL(ctx, eventURL); ok {
+		eventURL = event.URL
+	} else if eventURL = canonicalEventURL(eventURL); !isHTTPSURL(eventURL) {
+		http.Error(w, "unknown event", http.StatusNotFound)
+		return
+	}
+
+	tags := splitList(r.FormValue("tags"))
+	for _, tag := range tags {
+		if _, ok := currentSettings(ctx).Tags[tag]; !ok {
+			http.Error(w, fmt.Sprintf("unknown tag %q", tag), http.StatusBadRequest)
+			return
+		}
+	}
+
+	key := eventKey(ctx, tagsKind, eventURL)
+	if _, err := datastore.Put(ctx, key, &EventTags{Tags: tags, UpdatedAt: time.Now()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "tagged %s: %s\n", eventURL, strings.Join(tags, ","))
+}
@@ -0,0 +1,85 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	talkKind           = "TalkProposal"
+	defaultProgramSlot = 2
+)
+
+// TalkProposal is a talk submitted for an event
+type TalkProposal struct {
+	EventURL  string
+	UserID    string
+	Title     string
+	CreatedAt time.Time
+}
+
+func talkProposals(ctx context.Context, eventURL string) []TalkProposal {
+	var proposals []TalkProposal
+	if _, err := datastore.NewQuery(talkKind).Filter("EventURL =", eventURL).GetAll(ctx, &proposals); err != nil {
+		log.Errorf(ctx, "talk query %s: %v", eventURL, err)
+	}
+
+	return proposals
+}
+
+func formatLineup(proposals []TalkProposal) string {
+	var lines []string
+	for _, p := range proposals {
+		lines = append(lines, fmt.Sprintf("• %s (<@%s>)", p.Title, p.UserID))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commandTalk handles "/nfug talk <title>" for the next event
+func commandTalk(ctx context.Context, userID string, args []string) string {
+	if len(args) == 0 {
+		return textCommandUsage
+	}
+
+	event, ok := nextEvent(ctx)
+	if !ok {
+		return "次のイベントが見つかりませんでした。"
+	}
+
+	proposal := TalkProposal{
+		EventURL:  event.URL,
+		UserID:    userID,
+		Title:     strings.Join(args, " "),
+		CreatedAt: time.Now(),
+	}
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, talkKind, nil), &proposal); err != nil {
+		return err.Error()
+	}
+
+	text := fmt.Sprintf("『%s』に発表の申し込みがありました: %s (<@%s>)", event.Title, proposal.Title, userID)
+	if _, err := postMessage(ctx, seriesConfigFor(ctx, event).ManageChannel, text); err != nil {
+		log.Errorf(ctx, "talk post: %v", err)
+	}
+
+	return fmt.Sprintf("『%s』に「%s」を登録しました。", event.Title, proposal.Title)
+}
+
+// commandTalks handles "/nfug talks"
+func commandTalks(ctx context.Context) string {
+	event, ok := nextEvent(ctx)
+	if !ok {
+		return "次のイベントが見つかりませんでした。"
+	}
+
+	proposals := talkProposals(ctx, event.URL)
+	if len(proposals) == 0 {
+		return fmt.Sprintf("『%s』の発表はまだありません。", event.Title)
+	}
+
+	return fmt.Sprintf("『%s』の発表 (%d/%d枠)\n%s", event.Title, len(proposals), currentSettings(ctx).ProgramSlots, formatLineup(proposals))
+}
@@ -0,0 +1,188 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const (
+	taskKind         = "ManageTask"
+	taskEscalation   = 24 * time.Hour
+	notifyTaskRepost = "task_escalation"
+	notifyTaskLead   = "task_lead"
+	textTaskEscalate = "%s このお知らせに24時間リアクションがありません。対応できる方は 👀 (確認中) か ✅ (対応済み) をお願いします。\n>%s"
+	textTaskLead     = "#manage のお知らせに48時間だれも反応していません。対応をお願いします。\n>%s"
+)
+
+// taskReactions acknowledge an organizer task in #manage
+var taskReactions = map[string]bool{
+	"eyes":             true,
+	"white_check_mark": true,
+}
+
+// ManageTask is an organizer notification posted to #manage, tracked until someone reacts to it
+type ManageTask struct {
+	Type      string
+	EventURL  string
+	ChannelID string
+	TS        string
+	// RepostTS is the ts of the escalation, which can be reacted to as well
+	RepostTS       string
+	Text           string `datastore:",noindex"`
+	PostedAt       time.Time
+	EscalatedAt    time.Time
+	LeadNotifiedAt time.Time
+	Done           bool
+}
+
+// reactionAddedEvent ref: https://api.slack.com/events/reaction_added
+type reactionAddedEvent struct {
+	User     string `json:"user"`
+	Reaction string `json:"reaction"`
+	Item     struct {
+		Type    string `json:"type"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	} `json:"item"`
+}
+
+// trackTask starts tracking an organizer notification posted to a channel
+func trackTask(ctx context.Context, kind string, event Event, channel, text string, posted slackAPIResponse) {
+	rule, ok := rules.Find(kind)
+	if !ok || rule.Channel != rules.Manage || isUserID(channel) || posted.TS == "" {
+		return
+	}
+
+	task := ManageTask{
+		Type:      kind,
+		EventURL:  event.URL,
+		ChannelID: posted.Channel,
+		TS:        posted.TS,
+		Text:      text,
+		PostedAt:  time.Now(),
+	}
+	if _, err := datastore.Put(ctx, datastore.NewIncompleteKey(ctx, taskKind, nil), &task); err != nil {
+		log.Errorf(ctx, "task put %s: %v", kind, err)
+	}
+}
+
+// acknowledgeTask marks the task reacted to with 👀 or ✅ as done
+func acknowledgeTask(ctx context.Context, reaction reactionAddedEvent) {
+	if !taskReactions[reaction.Reaction] || reaction.Item.Type != "message" {
+		return
+	}
+
+	for _, field := range []string{"TS =", "RepostTS ="} {
+		var tasks []ManageTask
+		keys, err := datastore.NewQuery(taskKind).Filter("ChannelID =", reaction.Item.Channel).Filter(field, reaction.Item.TS).GetAll(ctx, &tasks)
+		if err != nil {
+			log.Errorf(ctx, "task query: %v", err)
+			return
+		}
+
+		for i, task := range tasks {
+			if task.Done {
+				continue
+			}
+			task.Done = true
+			if _, err := datastore.Put(ctx, keys[i], &task); err != nil {
+				log.Errorf(ctx, "task put %s: %v", task.Type, err)
+			}
+		}
+	}
+}
+
+// trackRepost records the ts of a posted escalation on its task, so that reacting to the repost acknowledges the task
+func trackRepost(ctx context.Context, event Event, channel, text string, posted slackAPIResponse) {
+	if posted.TS == "" {
+		return
+	}
+
+	var tasks []ManageTask
+	keys, err := datastore.NewQuery(taskKind).Filter("EventURL =", event.URL).Filter("Done =", false).GetAll(ctx, &tasks)
+	if err != nil {
+		log.Errorf(ctx, "task query: %v", err)
+		return
+	}
+	for i, task := range tasks {
+		if task.RepostTS != "" || task.EscalatedAt.IsZero() || task.ChannelID != channel || !strings.HasSuffix(text, ">"+task.Text) {
+			continue
+		}
+		task.RepostTS = posted.TS
+		if _, err := datastore.Put(ctx, keys[i], &task); err != nil {
+			log.Errorf(ctx, "task put %s: %v", task.Type, err)
+		}
+		return
+	}
+}
+
+// escalateTasks re-posts organizer tasks nobody reacted to within 24 hours with a mention of the organizers,
+// then DMs the community lead after another 24 hours.
+// The task is updated before notify, so a deferred or held escalation isn't posted again by the next cron run.
+func escalateTasks(ctx context.Context, w http.ResponseWriter, now time.Time) {
+	if botToken(ctx) == "" {
+		return
+	}
+
+	var tasks []ManageTask
+	keys, err := datastore.NewQuery(taskKind).Filter("Done =", false).GetAll(ctx, &tasks)
+	if err != nil {
+		log.Errorf(ctx, "task query: %v", err)
+		return
+	}
+
+	s := currentSettings(ctx)
+	for i, task := range tasks {
+		switch {
+		case task.EscalatedAt.IsZero():
+			if now.Sub(task.PostedAt) < taskEscalation {
+				continue
+			}
+
+			mention := "<!here>"
+			if s.OrganizersGroup != "" {
+				mention = fmt.Sprintf("<!subteam^%s>", s.OrganizersGroup)
+			}
+			task.EscalatedAt = now
+			if !saveTask(ctx, keys[i], task) {
+				continue
+			}
+			event := Event{URL: task.EventURL}
+			text := fmt.Sprintf(textTaskEscalate, mention, task.Text)
+			if needsApproval(ctx, task.ChannelID, text) {
+				requestApproval(ctx, w, notifyTaskRepost, event, task.ChannelID, text, nil)
+			} else {
+				notify(ctx, w, notifyTaskRepost, event, task.ChannelID, text)
+			}
+		case task.LeadNotifiedAt.IsZero():
+			if now.Sub(task.EscalatedAt) < taskEscalation {
+				continue
+			}
+
+			task.LeadNotifiedAt = now
+			task.Done = true
+			if !saveTask(ctx, keys[i], task) {
+				continue
+			}
+			// without a lead the task stays escalated only
+			if s.Lead != "" {
+				notify(ctx, w, notifyTaskLead, Event{URL: task.EventURL}, s.Lead, fmt.Sprintf(textTaskLead, task.Text))
+			}
+		}
+	}
+}
+
+func saveTask(ctx context.Context, key *datastore.Key, task ManageTask) bool {
+	if _, err := datastore.Put(ctx, key, &task); err != nil {
+		log.Errorf(ctx, "task put %s: %v", task.Type, err)
+		return false
+	}
+	return true
+}
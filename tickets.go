@@ -0,0 +1,118 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const ticketsKind = "TicketBreakdown"
+
+// the connpass API only has the totals, so the counts per participation type are read from the event page
+var (
+	ticketNameRe    = regexp.MustCompile(`class="ptype_name"[^>]*>\s*([^<]+?)\s*<`)
+	ticketAmountRe  = regexp.MustCompile(`<span class="amount"><span>(\d+)</span>/(\d+)</span>`)
+	ticketWaitingRe = regexp.MustCompile(`補欠[^\d<]*(?:<[^>]+>)?\s*(\d+)`)
+)
+
+// TicketBreakdown is the counts per participation type of an event, keyed by event ID
+type TicketBreakdown struct {
+	EventURL  string
+	Tickets   []rules.Ticket
+	FetchedAt time.Time
+}
+
+// parseTickets reads the participation types from the event page, in the order connpass lists them
+func parseTickets(body string) []rules.Ticket {
+	var tickets []rules.Ticket
+	names := ticketNameRe.FindAllStringSubmatchIndex(body, -1)
+	for i, match := range names {
+		end := len(body)
+		if i+1 < len(names) {
+			end = names[i+1][0]
+		}
+		section := body[match[1]:end]
+
+		ticket := rules.Ticket{Name: body[match[2]:match[3]]}
+		if amount := ticketAmountRe.FindStringSubmatch(section); amount != nil {
+			ticket.Accepted, _ = strconv.Atoi(amount[1])
+			ticket.Limit, _ = strconv.Atoi(amount[2])
+		}
+		if waiting := ticketWaitingRe.FindStringSubmatch(section); waiting != nil {
+			ticket.Waiting, _ = strconv.Atoi(waiting[1])
+		}
+		tickets = append(tickets, ticket)
+	}
+	return tickets
+}
+
+func fetchTickets(ctx context.Context, event Event) ([]rules.Ticket, error) {
+	req, err := http.NewRequest(http.MethodGet, event.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client, cancel := outboundClient(ctx, connpassTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return parseTickets(string(body)), nil
+}
+
+// updateTickets stores the counts per participation type, called when connpass has changed
+func updateTickets(ctx context.Context, event Event) {
+	tickets, err := fetchTickets(ctx, event)
+	if err != nil {
+		log.Errorf(ctx, "tickets %s: %v", event.URL, err)
+		return
+	}
+	// a single type tells nothing the totals don't
+	if len(tickets) < 2 {
+		return
+	}
+
+	breakdown := TicketBreakdown{EventURL: event.URL, Tickets: tickets, FetchedAt: time.Now()}
+	if _, err := datastore.Put(ctx, eventKey(ctx, ticketsKind, event.URL), &breakdown); err != nil {
+		log.Errorf(ctx, "tickets put %s: %v", event.URL, err)
+	}
+}
+
+func loadTickets(ctx context.Context, event Event) []rules.Ticket {
+	var breakdown TicketBreakdown
+	if err := datastore.Get(ctx, eventKey(ctx, ticketsKind, event.URL), &breakdown); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			log.Errorf(ctx, "tickets get %s: %v", event.URL, err)
+		}
+		return nil
+	}
+	return breakdown.Tickets
+}
+
+// watchedTickets lists the ticket_alerts setting for the rules, trimmed
+func watchedTickets(ctx context.Context) []string {
+	var watched []string
+	for _, name := range currentSettings(ctx).TicketAlerts {
+		if name = strings.TrimSpace(name); name != "" {
+			watched = append(watched, name)
+		}
+	}
+	return watched
+}
@@ -0,0 +1,51 @@
+package slackbot
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"google.golang.org/appengine/log"
+)
+
+// usersInfoResponse ref: https://api.slack.com/methods/users.info
+type usersInfoResponse struct {
+	User struct {
+		TZ string `json:"tz"`
+	} `json:"user"`
+}
+
+// userLocation returns the timezone set in the Slack profile of the user, the local timezone if unknown
+func userLocation(ctx context.Context, userID string) *time.Location {
+	if botToken(ctx) == "" {
+		return time.Local
+	}
+
+	params := url.Values{}
+	params.Set("user", userID)
+
+	var result usersInfoResponse
+	if err := callSlackAPIGet(ctx, "users.info", params, &result); err != nil {
+		log.Warningf(ctx, "users.info %s: %v", userID, err)
+		return time.Local
+	}
+	if result.User.TZ == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(result.User.TZ)
+	if err != nil {
+		log.Warningf(ctx, "tz %s of %s: %v", result.User.TZ, userID, err)
+		return time.Local
+	}
+	return loc
+}
+
+// formatTimeIn formats t in loc, with the zone when it isn't the local one
+func formatTimeIn(t time.Time, layout string, loc *time.Location) string {
+	t = t.In(loc)
+	if loc.String() == time.Local.String() {
+		return t.Format(layout)
+	}
+	return t.Format(layout + " MST")
+}
@@ -0,0 +1,69 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const trelloCardsURL = "https://api.trello.com/1/cards"
+
+var (
+	trelloKey    = os.Getenv("TRELLO_KEY")
+	trelloToken  = os.Getenv("TRELLO_TOKEN")
+	trelloListID = os.Getenv("TRELLO_LIST_ID")
+)
+
+func isVenueUndecided(place string) bool {
+	place = strings.TrimSpace(place)
+	return place == "" || strings.Contains(place, "未定")
+}
+
+// createVenueCard creates a Trello card for booking the venue and returns its URL
+// ref: https://developer.atlassian.com/cloud/trello/rest/api-group-cards/#api-cards-post
+func createVenueCard(ctx context.Context, event Event) (string, error) {
+	if trelloKey == "" || trelloToken == "" || trelloListID == "" {
+		return "", nil
+	}
+
+	params := url.Values{}
+	params.Set("key", trelloKey)
+	params.Set("token", trelloToken)
+	params.Set("idList", trelloListID)
+	params.Set("name", fmt.Sprintf("『%s』の会場を確保する", event.Title))
+	params.Set("desc", fmt.Sprintf("%s\n%s 開催", event.URL, event.StartedAt.Format("2006/01/02 15:04")))
+	params.Set("due", event.StartedAt.Format(time.RFC3339))
+
+	req, err := http.NewRequest(http.MethodPost, trelloCardsURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("trello: %s", resp.Status)
+	}
+
+	var card struct {
+		ShortURL string `json:"shortUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		return "", err
+	}
+
+	return card.ShortURL, nil
+}
@@ -0,0 +1,111 @@
+package slackbot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/girigiribauer/nfug-eventbot/rules"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+const textVenue = "『%s』の会場はこちらです！\n会場: %s\n住所: %s\n地図: %s"
+
+var venueQuestionRe = regexp.MustCompile(`(会場|場所|アクセス).*(どこ|どちら|どうやって|教えて|[?？])`)
+
+// Venue is a known venue with notes for participants, keyed by the connpass place name in settings.yaml
+type Venue struct {
+	Address string `yaml:"address"`
+	Access  string `yaml:"access"`
+	Door    string `yaml:"door"`
+	WiFi    string `yaml:"wifi"`
+	// Capacity is the physical capacity, checked against the connpass limit
+	Capacity int `yaml:"capacity"`
+}
+
+// notes renders the notes of the venue, one per line
+func (v Venue) notes() string {
+	var lines []string
+	for _, note := range []struct{ label, text string }{
+		{"アクセス", v.Access},
+		{"入館方法", v.Door},
+		{"Wi-Fi", v.WiFi},
+	} {
+		if note.text != "" {
+			lines = append(lines, note.label+": "+note.text)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// venueFor looks up the venue directory by the place of the event
+func venueFor(ctx context.Context, event Event) (Venue, bool) {
+	venue, ok := currentSettings(ctx).Venues[event.Place]
+	return venue, ok
+}
+
+// usualLimit returns the average connpass limit of past events at the place, 0 if unknown
+func usualLimit(ctx context.Context, event Event) int {
+	var archived []ArchivedEvent
+	if _, err := datastore.NewQuery(archiveKind).Filter("Place =", event.Place).GetAll(ctx, &archived); err != nil {
+		log.Errorf(ctx, "archive query %s: %v", event.Place, err)
+		return 0
+	}
+
+	total, count := 0, 0
+	for _, past := range archived {
+		if past.URL != event.URL && past.Limit > 0 && isEnded(past.EndedAt) {
+			total += past.Limit
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
+
+// mapURL returns a Google Maps link of the event location
+func mapURL(event Event) string {
+	query := event.Address
+	if event.Lat != "" && event.Lon != "" {
+		query = event.Lat + "," + event.Lon
+	}
+	return "https://www.google.com/maps/search/?api=1&query=" + url.QueryEscape(query)
+}
+
+// answerVenue replies in thread with the venue of today's event when a member asks for it
+func answerVenue(ctx context.Context, message messageEvent) {
+	if !venueQuestionRe.MatchString(message.Text) {
+		return
+	}
+
+	event, ok := nextEvent(ctx)
+	if !ok || !rules.IsDaysBefore(event.StartedAt, time.Now(), 0) {
+		return
+	}
+
+	threadTS := message.ThreadTS
+	if threadTS == "" {
+		threadTS = message.TS
+	}
+
+	text := fmt.Sprintf(textVenue, event.Title, event.Place, event.Address, mapURL(event))
+	if venue, ok := venueFor(ctx, event); ok {
+		text += "\n" + venue.notes()
+	}
+	if _, err := callSlackAPI(ctx, "chat.postMessage", map[string]interface{}{
+		"channel":   message.Channel,
+		"thread_ts": threadTS,
+		"text":      text,
+	}); err != nil {
+		log.Errorf(ctx, "venue answer: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package slackbot
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+var (
+	// inflight counts requests that may still send notifications or write audit entries, waited for on shutdown
+	inflight sync.WaitGroup
+	// draining is set on shutdown, after which tracked handlers answer 503. It is guarded by drainMu,
+	// so that no request is added to inflight once drain waits for it.
+	draining bool
+	drainMu  sync.Mutex
+	// drained is closed on shutdown, ending the waits of running requests
+	drained = make(chan struct{})
+)
+
+// tracked counts the handler in inflight while it runs
+func tracked(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		drainMu.Lock()
+		if draining {
+			drainMu.Unlock()
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		inflight.Add(1)
+		drainMu.Unlock()
+
+		defer inflight.Done()
+		h(w, r)
+	}
+}
+
+// drain stops accepting tracked requests and waits for the running ones
+func drain() {
+	drainMu.Lock()
+	if !draining {
+		draining = true
+		close(drained)
+	}
+	drainMu.Unlock()
+
+	inflight.Wait()
+}
+
+// handleWarmup loads the stored settings of all communities and checks the Slack credentials
+// before the instance receives traffic. It also serves as the startup probe on Cloud Run.
+// ref: https://cloud.google.com/appengine/docs/standard/configuring-warmup-requests
+func handleWarmup(w http.ResponseWriter, r *http.Request) {
+	ctx := appengine.NewContext(r)
+	if len(configErrors) > 0 {
+		http.Error(w, "misconfigured", http.StatusInternalServerError)
+		return
+	}
+
+	refreshSettings(ctx)
+	for _, name := range communityNames() {
+		cctx, err := withCommunity(ctx, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if botToken(cctx) == "" {
+			continue
+		}
+		// ref: https://api.slack.com/methods/auth.test
+		if _, err := callSlackAPI(cctx, "auth.test", map[string]interface{}{}); err != nil {
+			log.Errorf(ctx, "warmup %q: %v", name, err)
+			http.Error(w, fmt.Sprintf("slack credentials of %q: %v", name, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,99 @@
+package slackbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"google.golang.org/appengine/log"
+)
+
+const (
+	// Open-Meteo needs no API key
+	// ref: https://open-meteo.com/en/docs
+	weatherURL  = "https://api.open-meteo.com/v1/forecast"
+	textWeather = "会場の天気: %s (最高 %.0f℃ / 降水確率 %d%%)\n"
+)
+
+// weatherNames are the WMO weather codes of Open-Meteo, the lower bound of each group
+var weatherNames = []struct {
+	code int
+	name string
+}{
+	{0, "快晴"},
+	{1, "晴れ"},
+	{3, "くもり"},
+	{45, "霧"},
+	{51, "霧雨"},
+	{61, "雨"},
+	{71, "雪"},
+	{80, "にわか雨"},
+	{85, "にわか雪"},
+	{95, "雷雨"},
+}
+
+func weatherName(code int) string {
+	name := ""
+	for _, w := range weatherNames {
+		if code >= w.code {
+			name = w.name
+		}
+	}
+	return name
+}
+
+// weather returns today's forecast at the venue, or "" when the location is unknown or the request fails
+func weather(ctx context.Context, event Event) string {
+	if event.Lat == "" || event.Lon == "" {
+		return ""
+	}
+
+	params := url.Values{}
+	params.Set("latitude", event.Lat)
+	params.Set("longitude", event.Lon)
+	params.Set("daily", "weathercode,temperature_2m_max,precipitation_probability_max")
+	params.Set("timezone", location)
+	params.Set("forecast_days", "1")
+
+	req, err := http.NewRequest(http.MethodGet, weatherURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return ""
+	}
+
+	client, cancel := outboundClient(ctx, outboundTimeout)
+	defer cancel()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Warningf(ctx, "weather %s: %v", event.URL, err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		log.Warningf(ctx, "weather %s: unexpected status %s", event.URL, resp.Status)
+		return ""
+	}
+
+	var forecast struct {
+		Daily struct {
+			WeatherCode              []int     `json:"weathercode"`
+			TemperatureMax           []float64 `json:"temperature_2m_max"`
+			PrecipitationProbability []int     `json:"precipitation_probability_max"`
+		} `json:"daily"`
+	}
+	if err := json.Unmarshal(body, &forecast); err != nil {
+		log.Warningf(ctx, "weather %s: %v", event.URL, err)
+		return ""
+	}
+	daily := forecast.Daily
+	if len(daily.WeatherCode) == 0 || len(daily.TemperatureMax) == 0 || len(daily.PrecipitationProbability) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(textWeather, weatherName(daily.WeatherCode[0]), daily.TemperatureMax[0], daily.PrecipitationProbability[0])
+}